@@ -1,19 +1,97 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"time"
 
 	hopper "github.com/edwces/hopper/pkg"
 )
 
-// Todo: some basic CLI here
+const usage = `Usage:
+  hopper crawl [flags] <url>...   print urls reached from seeds
+  hopper mirror [flags] <url>...  download site with its assets for offline browsing
+`
+
 func main() {
-	crawler := hopper.Crawler{}
-    
-    crawler.BeforeRequest = func(r *hopper.Request) {
-        fmt.Println(r.URL.String())
-    }
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "crawl":
+		crawl(os.Args[2:])
+	case "mirror":
+		mirror(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// flags parses crawler options shared by commands.
+func flags(name string, args []string, extra func(*flag.FlagSet)) (*hopper.Crawler, []string) {
+	crawler := &hopper.Crawler{}
+
+	set := flag.NewFlagSet(name, flag.ExitOnError)
+	set.IntVar(&crawler.AllowedDepth, "depth", 0, "maximum depth of crawled links, unlimited if 0")
+	set.IntVar(&crawler.Concurrency, "concurrency", 0, "number of workers, GOMAXPROCS if 0")
+	set.DurationVar(&crawler.Delay, "delay", time.Second, "delay between requests to same host")
+	set.BoolVar(&crawler.ScanScripts, "scan-scripts", false, "follow url-like string literals of scripts")
+	set.BoolVar(&crawler.SubmitForms, "submit-forms", false, "follow submissions of GET forms with their default values")
+	set.StringVar(&crawler.UserAgent, "user-agent", hopper.DefaultUserAgent, "user agent of requests")
+	if extra != nil {
+		extra(set)
+	}
+	set.Parse(args)
+
+	if set.NArg() == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		set.PrintDefaults()
+		os.Exit(2)
+	}
+
+	return crawler, set.Args()
+}
 
+func crawl(args []string) {
+	crawler, seeds := flags("crawl", args, nil)
 	crawler.Init()
-	crawler.Run("https://en.wikipedia.org/wiki/Main_Page")
+
+	crawler.OnRequest(func(r *hopper.Request) error {
+		fmt.Println(r.URL.String())
+		return nil
+	})
+	crawler.OnError(func(r *hopper.Request, err error) {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", r.URL, err)
+	})
+
+	crawler.Run(seeds...)
+}
+
+func mirror(args []string) {
+	dir := "."
+	crawler, seeds := flags("mirror", args, func(set *flag.FlagSet) {
+		set.StringVar(&dir, "dir", dir, "directory mirror is written to")
+	})
+	// Mirror stays on hosts of seeds, like wget --mirror does.
+	crawler.Scope = hopper.ScopeHost
+	crawler.Init()
+
+	mm := hopper.Mirror(crawler, dir)
+	crawler.OnResponse(func(r *hopper.Response) error {
+		fmt.Println(r.URL.String())
+		return nil
+	})
+	crawler.OnError(func(r *hopper.Request, err error) {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", r.URL, err)
+	})
+
+	crawler.Run(seeds...)
+	if err := mm.ConvertLinks(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -5,3 +5,9 @@ go 1.20
 require golang.org/x/net v0.8.0
 
 require github.com/temoto/robotstxt v1.1.2
+
+require github.com/andybalholm/brotli v1.1.0
+
+require github.com/klauspost/compress v1.17.9
+
+require golang.org/x/text v0.8.0 // indirect
@@ -0,0 +1,245 @@
+package hopper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Paginator returns request for page following response, or nil when
+// pages are exhausted.
+type Paginator func(res *Response) (*Request, error)
+
+// NextLink follows url found at path of json response.
+func NextLink(path *JSONPath) Paginator {
+	return func(res *Response) (*Request, error) {
+		doc, err := res.JSON()
+		if err != nil {
+			return nil, err
+		}
+
+		links := path.Strings(doc)
+		if len(links) == 0 || links[0] == "" {
+			return nil, nil
+		}
+		return res.page(links[0])
+	}
+}
+
+// LinkHeader follows url of Link header with rel next.
+func LinkHeader() Paginator {
+	return func(res *Response) (*Request, error) {
+		for _, header := range res.Headers.Values("Link") {
+			for _, link := range strings.Split(header, ",") {
+				target, params, found := strings.Cut(link, ";")
+				if !found {
+					continue
+				}
+				for _, param := range strings.Split(params, ";") {
+					key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+					if strings.EqualFold(key, "rel") && containsField(strings.ToLower(strings.Trim(value, `"`)), "next") {
+						return res.page(strings.Trim(strings.TrimSpace(target), "<>"))
+					}
+				}
+			}
+		}
+		return nil, nil
+	}
+}
+
+// PageNumber increments page number in query param, starting from
+// first page, while items path of response selects any item.
+func PageNumber(param string, first int, items *JSONPath) Paginator {
+	return func(res *Response) (*Request, error) {
+		return res.counter(param, first, items, func(current int, n int) int { return current + 1 })
+	}
+}
+
+// Offset increments offset in query param by number of items selected
+// by items path, while there are any.
+func Offset(param string, items *JSONPath) Paginator {
+	return func(res *Response) (*Request, error) {
+		return res.counter(param, 0, items, func(current int, n int) int { return current + n })
+	}
+}
+
+// Cursor sets query param to token found at path of json response,
+// while there is one.
+func Cursor(param string, path *JSONPath) Paginator {
+	return func(res *Response) (*Request, error) {
+		doc, err := res.JSON()
+		if err != nil {
+			return nil, err
+		}
+
+		var token string
+		if values := path.Find(doc); len(values) > 0 {
+			switch v := values[0].(type) {
+			case string:
+				token = v
+			case float64:
+				token = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		if token == "" {
+			return nil, nil
+		}
+
+		uri := *res.URL
+		query := uri.Query()
+		query.Set(param, token)
+		uri.RawQuery = query.Encode()
+		return res.page(uri.String())
+	}
+}
+
+// counter pushes next page of counter param while response has items.
+func (res *Response) counter(param string, first int, items *JSONPath, next func(current int, n int) int) (*Request, error) {
+	doc, err := res.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	n := 0
+	for _, item := range items.Find(doc) {
+		if arr, ok := item.([]any); ok {
+			n += len(arr)
+		} else {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	current := first
+	query := res.URL.Query()
+	if value := query.Get(param); value != "" {
+		if current, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("Query param %s: %w", param, err)
+		}
+	}
+
+	uri := *res.URL
+	query.Set(param, strconv.Itoa(next(current, n)))
+	uri.RawQuery = query.Encode()
+	return res.page(uri.String())
+}
+
+// page creates request for another page of response. Pages are not
+// deeper than the page they follow.
+func (res *Response) page(uri string) (*Request, error) {
+	base := *res.Request
+	base.URL = res.URL
+	base.Depth--
+
+	return base.New(http.MethodGet, uri)
+}
+
+// JSON decodes body of response, decoded document is cached.
+func (res *Response) JSON() (any, error) {
+	if res.json == nil {
+		body, err := res.Bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, err
+		}
+		res.json = &doc
+	}
+
+	return *res.json, nil
+}
+
+// IsJSON reports whether response has json content type.
+func (res *Response) IsJSON() bool {
+	mediaType, _, err := mime.ParseMediaType(res.Headers.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+type APIMiddleware struct {
+	Links      []*JSONPath
+	Paginators []Paginator
+}
+
+// API crawls json responses. Urls selected by links paths are pushed as
+// new requests, paginators push following pages until they are
+// exhausted. Rejected links and errors of paginators are passed to error
+// handlers of crawler. Requests accept json unless they set Accept header.
+func API(crawler *Crawler, links []*JSONPath, paginators ...Paginator) *APIMiddleware {
+	am := &APIMiddleware{Links: links, Paginators: paginators}
+
+	crawler.OnRequest(func(r *Request) error {
+		if r.Headers.Get("Accept") == "" {
+			r.Headers.Set("Accept", "application/json")
+		}
+		return nil
+	})
+
+	crawler.OnResponse(func(r *Response) error {
+		if r.NotModified || !r.IsJSON() {
+			return nil
+		}
+
+		// Pages of other paginators are crawled even when some failed.
+		discovered, err := am.Discover(r)
+		r.Discovered = append(r.Discovered, discovered...)
+		if err != nil {
+			crawler.report(r.Request, fmt.Errorf("API: %w", err))
+		}
+
+		return nil
+	})
+
+	return am
+}
+
+// Discover returns requests for links and next pages of json response,
+// along with joined errors of links which were rejected and paginators
+// which failed.
+func (am *APIMiddleware) Discover(res *Response) ([]*Request, error) {
+	doc, err := res.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	base := *res.Request
+	base.URL = res.URL
+
+	discovered := []*Request{}
+	failed := []error{}
+	for _, path := range am.Links {
+		for _, link := range path.Strings(doc) {
+			req, err := base.New(http.MethodGet, link)
+			if err != nil {
+				failed = append(failed, fmt.Errorf("Link %s: %w", link, err))
+				continue
+			}
+			discovered = append(discovered, req)
+		}
+	}
+
+	for _, paginator := range am.Paginators {
+		req, err := paginator(res)
+		if err != nil {
+			failed = append(failed, fmt.Errorf("Paginator: %w", err))
+			continue
+		}
+		if req == nil {
+			continue
+		}
+		discovered = append(discovered, req)
+	}
+
+	return discovered, errors.Join(failed...)
+}
@@ -0,0 +1,168 @@
+package hopper
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestPaginators(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		switch r.URL.Path {
+		case "/link":
+			w.Header().Set("Link", `</link?page=1>; rel="prev", </link?page=3>; rel="next"`)
+			io.WriteString(w, `{}`)
+		case "/empty":
+			io.WriteString(w, `{"items": [], "next": null, "cursor": ""}`)
+		default:
+			io.WriteString(w, `{"items": [{"url": "/a"}, {"url": "/b"}], "next": "/items?page=2", "cursor": "abc"}`)
+		}
+	}))
+	defer srv.Close()
+
+	client := &Client{Client: &http.Client{}}
+	client.Init()
+
+	parent := &Request{}
+	parent.Init()
+	parent.URL, _ = url.Parse(srv.URL)
+	parent.Properties["AllowedDepth"] = 10
+	parent.Properties["ContentLength"] = int64(1 << 20)
+
+	items := MustCompileJSONPath("$.items")
+	tests := []struct {
+		Name      string
+		Path      string
+		Paginator Paginator
+		Want      string
+	}{
+		{"NextLink", "/items", NextLink(MustCompileJSONPath("$.next")), "/items?page=2"},
+		{"NextLinkExhausted", "/empty", NextLink(MustCompileJSONPath("$.next")), ""},
+		{"LinkHeader", "/link", LinkHeader(), "/link?page=3"},
+		{"PageNumber", "/items?page=4", PageNumber("page", 1, items), "/items?page=5"},
+		{"PageNumberFirst", "/items", PageNumber("page", 1, items), "/items?page=2"},
+		{"PageNumberExhausted", "/empty?page=4", PageNumber("page", 1, items), ""},
+		{"Offset", "/items?limit=2&offset=2", Offset("offset", items), "/items?limit=2&offset=4"},
+		{"Cursor", "/items?cursor=x", Cursor("cursor", MustCompileJSONPath("$.cursor")), "/items?cursor=abc"},
+		{"CursorExhausted", "/empty?cursor=x", Cursor("cursor", MustCompileJSONPath("$.cursor")), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			req, _ := parent.New(http.MethodGet, test.Path)
+			httpRes, err := client.DoRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := NewResponse(httpRes, req.Properties, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next, err := test.Paginator(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := ""
+			if next != nil {
+				got = next.URL.RequestURI()
+				if next.Depth != req.Depth {
+					t.Fatalf("Request.Depth = %d, want %d", next.Depth, req.Depth)
+				}
+			}
+			if got != test.Want {
+				t.Fatalf("Paginator = %s, want %s", got, test.Want)
+			}
+		})
+	}
+
+	t.Run("API", func(t *testing.T) {
+		crawler := Crawler{Client: srv.Client(), Delay: TestDelay}
+		crawler.Init()
+		API(&crawler, []*JSONPath{MustCompileJSONPath("$.items[*].url")}, NextLink(MustCompileJSONPath("$.next")))
+
+		var mu sync.Mutex
+		pushed := map[string]bool{}
+		crawler.OnPush(func(r *Request) error {
+			mu.Lock()
+			defer mu.Unlock()
+			pushed[r.URL.RequestURI()] = true
+			return nil
+		})
+
+		crawler.Run(srv.URL + "/items")
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, want := range []string{"/a", "/b", "/items?page=2"} {
+			if !pushed[want] {
+				t.Fatalf("pushed = %v, want %s", pushed, want)
+			}
+		}
+	})
+
+	t.Run("PaginatorError", func(t *testing.T) {
+		crawler := Crawler{Client: srv.Client(), Delay: TestDelay}
+		crawler.Init()
+		API(&crawler, nil, PageNumber("page", 1, items), Cursor("cursor", MustCompileJSONPath("$.cursor")))
+
+		var mu sync.Mutex
+		var visitErr error
+		pushed := map[string]bool{}
+		crawler.OnError(func(r *Request, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if visitErr == nil {
+				visitErr = err
+			}
+		})
+		crawler.OnPush(func(r *Request) error {
+			mu.Lock()
+			defer mu.Unlock()
+			pushed[r.URL.RequestURI()] = true
+			return nil
+		})
+
+		// Page number can't be incremented, cursor still pages.
+		crawler.Run(srv.URL + "/items?page=first")
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !errors.Is(visitErr, strconv.ErrSyntax) {
+			t.Fatalf("err = %v, want %v", visitErr, strconv.ErrSyntax)
+		}
+		if !pushed["/items?cursor=abc&page=first"] {
+			t.Fatalf("pushed = %v, want page of cursor", pushed)
+		}
+	})
+	t.Run("RejectedLink", func(t *testing.T) {
+		crawler := Crawler{Client: srv.Client(), Filters: []Filter{DisallowPaths("/b")}, AllowedDepth: 1, Delay: TestDelay}
+		crawler.Init()
+		API(&crawler, []*JSONPath{MustCompileJSONPath("$.items[*].url")})
+
+		var mu sync.Mutex
+		var visitErr error
+		crawler.OnError(func(r *Request, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if r.URL.Path == "/items" {
+				visitErr = err
+			}
+		})
+
+		crawler.Run(srv.URL + "/items")
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !errors.Is(visitErr, ErrFiltered) {
+			t.Fatalf("err = %v, want %v", visitErr, ErrFiltered)
+		}
+	})
+}
@@ -0,0 +1,550 @@
+package hopper
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// tokenExpiryMargin is how long before expiry tokens are refreshed.
+const tokenExpiryMargin = 30 * time.Second
+
+var (
+	ErrLoginFormNotFound = errors.New("Login form not found")
+	ErrLoginFailed       = errors.New("Login failed")
+	ErrCrossHostForm     = errors.New("Login form submits to other host")
+)
+
+// Authenticator adds credentials to requests of domain it is registered
+// for with Client.Authenticate.
+type Authenticator interface {
+	// Authenticate adds credentials to req which is sent with client,
+	// logging in first if necessary.
+	Authenticate(client *http.Client, req *http.Request) error
+	// Expired reports whether res shows that credentials are missing or no
+	// longer valid. Request is then authenticated and sent once again.
+	Expired(client *http.Client, res *http.Response) bool
+}
+
+// BasicAuth sends HTTP Basic credentials.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (ba *BasicAuth) Authenticate(client *http.Client, req *http.Request) error {
+	req.SetBasicAuth(ba.Username, ba.Password)
+	return nil
+}
+
+func (ba *BasicAuth) Expired(client *http.Client, res *http.Response) bool {
+	return false
+}
+
+// BearerAuth sends static bearer token.
+type BearerAuth struct {
+	Token string
+}
+
+func (ba *BearerAuth) Authenticate(client *http.Client, req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+ba.Token)
+	return nil
+}
+
+func (ba *BearerAuth) Expired(client *http.Client, res *http.Response) bool {
+	return false
+}
+
+// DigestAuth answers HTTP Digest challenges with MD5 or SHA-256 and
+// qop=auth. First request of each protection space is sent without
+// credentials to obtain challenge.
+type DigestAuth struct {
+	sync.Mutex
+
+	Username string
+	Password string
+
+	challenge map[string]string
+	count     int
+}
+
+func (da *DigestAuth) Authenticate(client *http.Client, req *http.Request) error {
+	da.Lock()
+	defer da.Unlock()
+
+	if da.challenge == nil {
+		return nil
+	}
+
+	newHash := md5.New
+	algorithm := da.challenge["algorithm"]
+	if strings.EqualFold(algorithm, "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(s string) string {
+		return hashHex(newHash(), s)
+	}
+
+	da.count++
+	nc := fmt.Sprintf("%08x", da.count)
+	cnonce := randomHex(8)
+	uri := req.URL.RequestURI()
+
+	ha1 := h(da.Username + ":" + da.challenge["realm"] + ":" + da.Password)
+	ha2 := h(req.Method + ":" + uri)
+
+	var response string
+	qop := ""
+	if containsField(strings.ReplaceAll(da.challenge["qop"], ",", " "), "auth") {
+		qop = "auth"
+		response = h(strings.Join([]string{ha1, da.challenge["nonce"], nc, cnonce, qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + da.challenge["nonce"] + ":" + ha2)
+	}
+
+	params := []string{
+		fmt.Sprintf(`username="%s"`, da.Username),
+		fmt.Sprintf(`realm="%s"`, da.challenge["realm"]),
+		fmt.Sprintf(`nonce="%s"`, da.challenge["nonce"]),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if algorithm != "" {
+		params = append(params, "algorithm="+algorithm)
+	}
+	if qop != "" {
+		params = append(params, "qop="+qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	if opaque, exists := da.challenge["opaque"]; exists {
+		params = append(params, fmt.Sprintf(`opaque="%s"`, opaque))
+	}
+	req.Header.Set("Authorization", "Digest "+strings.Join(params, ", "))
+
+	return nil
+}
+
+func (da *DigestAuth) Expired(client *http.Client, res *http.Response) bool {
+	if res.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	for _, header := range res.Header.Values("WWW-Authenticate") {
+		scheme, params, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		da.Lock()
+		da.challenge = parseAuthParams(params)
+		da.count = 0
+		da.Unlock()
+		return true
+	}
+
+	return false
+}
+
+// ClientCredentials obtains bearer tokens with OAuth2 client credentials
+// grant and refreshes them before they expire or when they are rejected.
+type ClientCredentials struct {
+	sync.Mutex
+
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	token  string
+	expiry time.Time
+}
+
+func (cc *ClientCredentials) Authenticate(client *http.Client, req *http.Request) error {
+	cc.Lock()
+	defer cc.Unlock()
+
+	if cc.token == "" || (!cc.expiry.IsZero() && time.Now().Add(tokenExpiryMargin).After(cc.expiry)) {
+		if err := cc.fetch(client); err != nil {
+			return fmt.Errorf("Token: %w", err)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+cc.token)
+
+	return nil
+}
+
+func (cc *ClientCredentials) Expired(client *http.Client, res *http.Response) bool {
+	if res.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	cc.Lock()
+	cc.token = ""
+	cc.Unlock()
+	return true
+}
+
+func (cc *ClientCredentials) fetch(client *http.Client) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(cc.Scopes) > 0 {
+		form.Set("scope", strings.Join(cc.Scopes, " "))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, cc.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cc.ClientID), url.QueryEscape(cc.ClientSecret))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Token endpoint responded with %d: %w", res.StatusCode, ErrLoginFailed)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return err
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("Token endpoint returned no token: %w", ErrLoginFailed)
+	}
+
+	cc.token = token.AccessToken
+	cc.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		cc.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return nil
+}
+
+// FormLogin logs in by submitting html form found on page at URL with
+// Fields filled in, keeping session in cookie jar of client. Login is
+// verified by presence of Verify selector on resulting page and is
+// repeated when session expiry is detected, once for all requests which
+// detected it. Requests waiting for login don't hold up others. Forms
+// submitting to other host than login page are refused, so that
+// credentials are not sent elsewhere.
+type FormLogin struct {
+	sync.Mutex
+
+	URL    string
+	Form   string
+	Fields map[string]string
+	Verify string
+	// IsExpired detects expired session, by default responses with 401
+	// or 403 and other pages redirected to login page are considered
+	// expired. Responses of login page itself never are.
+	IsExpired func(*http.Response) bool
+
+	loggedIn map[http.CookieJar]bool
+	// logins are logins in progress, which other requests wait for.
+	logins map[http.CookieJar]*formLoginCall
+}
+
+type formLoginCall struct {
+	done chan struct{}
+	err  error
+}
+
+func (fl *FormLogin) Authenticate(client *http.Client, req *http.Request) error {
+	fl.Lock()
+	if fl.loggedIn[client.Jar] {
+		fl.Unlock()
+		return nil
+	}
+	if call, exists := fl.logins[client.Jar]; exists {
+		fl.Unlock()
+		<-call.done
+		return call.err
+	}
+	if fl.logins == nil {
+		fl.logins = map[http.CookieJar]*formLoginCall{}
+	}
+	call := &formLoginCall{done: make(chan struct{})}
+	fl.logins[client.Jar] = call
+	fl.Unlock()
+
+	// Login is sent without lock, others wait only for its result.
+	if err := fl.login(client); err != nil {
+		call.err = fmt.Errorf("Login: %w", err)
+	}
+
+	fl.Lock()
+	delete(fl.logins, client.Jar)
+	if call.err == nil {
+		if fl.loggedIn == nil {
+			fl.loggedIn = map[http.CookieJar]bool{}
+		}
+		fl.loggedIn[client.Jar] = true
+	}
+	fl.Unlock()
+	close(call.done)
+
+	return call.err
+}
+
+func (fl *FormLogin) Expired(client *http.Client, res *http.Response) bool {
+	login, err := url.Parse(fl.URL)
+	if err != nil || res.Request == nil {
+		return false
+	}
+	isLogin := func(u *url.URL) bool {
+		return u.Host == login.Host && u.Path == login.Path
+	}
+
+	// Request which was sent first, before redirects.
+	first := res.Request
+	for first.Response != nil && first.Response.Request != nil {
+		first = first.Response.Request
+	}
+	if isLogin(first.URL) {
+		return false
+	}
+
+	expired := false
+	if fl.IsExpired != nil {
+		expired = fl.IsExpired(res)
+	} else if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		expired = true
+	} else {
+		expired = isLogin(res.Request.URL)
+	}
+
+	if expired {
+		fl.Lock()
+		// Session which was renewed since request was sent is kept.
+		if client.Jar == nil || res.Request.Header.Get("Cookie") == cookieHeader(client.Jar, res.Request.URL) {
+			delete(fl.loggedIn, client.Jar)
+		}
+		fl.Unlock()
+	}
+
+	return expired
+}
+
+// cookieHeader returns Cookie header jar would send to uri.
+func cookieHeader(jar http.CookieJar, uri *url.URL) string {
+	probe := &http.Request{Header: http.Header{}}
+	for _, cookie := range jar.Cookies(uri) {
+		probe.AddCookie(cookie)
+	}
+	return probe.Header.Get("Cookie")
+}
+
+func (fl *FormLogin) login(client *http.Client) error {
+	res, err := client.Get(fl.URL)
+	if err != nil {
+		return err
+	}
+	doc, err := html.Parse(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	var form *Form
+	if fl.Form == "" {
+		forms := ParseForms(res.Request.URL, doc)
+		if len(forms) > 0 {
+			form = forms[0]
+		}
+	} else if node := findSelector(doc, fl.Form); node != nil && node.Data == "form" {
+		form = parseForm(res.Request.URL, node)
+	}
+	if form == nil {
+		return ErrLoginFormNotFound
+	}
+
+	for name, value := range fl.Fields {
+		form.Values.Set(name, value)
+	}
+
+	action, body, err := form.submission()
+	if err != nil {
+		return err
+	}
+	if action.Host != res.Request.URL.Host {
+		return fmt.Errorf("Action %s: %w", action.Host, ErrCrossHostForm)
+	}
+
+	var submit *http.Request
+	if body != nil {
+		submit, err = http.NewRequest(form.Method, action.String(), body.Reader())
+		if err == nil {
+			submit.Header.Set("Content-Type", body.ContentType)
+		}
+	} else {
+		submit, err = http.NewRequest(form.Method, action.String(), nil)
+	}
+	if err != nil {
+		return err
+	}
+	submit.Header.Set("Referer", res.Request.URL.String())
+
+	res, err = client.Do(submit)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("Login form responded with %d: %w", res.StatusCode, ErrLoginFailed)
+	}
+	if fl.Verify == "" {
+		return nil
+	}
+
+	doc, err = html.Parse(res.Body)
+	if err != nil {
+		return err
+	}
+	if findSelector(doc, fl.Verify) == nil {
+		return fmt.Errorf("Selector %s not found after login: %w", fl.Verify, ErrLoginFailed)
+	}
+
+	return nil
+}
+
+// authenticator returns authenticator registered for host of uri,
+// preferring exact domain over wildcard one.
+func (c *Client) authenticator(uri *url.URL) Authenticator {
+	if auth, exists := c.Authenticators[uri.Hostname()]; exists {
+		return auth
+	}
+	for domain, auth := range c.Authenticators {
+		if matchDomain(uri.Hostname(), []string{domain}) {
+			return auth
+		}
+	}
+
+	return nil
+}
+
+// Authenticate registers authenticator for domain, which can be prefixed
+// with "*." to match its subdomains.
+func (c *Client) Authenticate(domain string, auth Authenticator) {
+	if c.Authenticators == nil {
+		c.Authenticators = map[string]Authenticator{}
+	}
+	c.Authenticators[domain] = auth
+}
+
+// sendAuthenticated sends req with credentials of its domain and retries
+// it once if they turn out to be expired.
+func (c *Client) sendAuthenticated(client *http.Client, req *http.Request) (*http.Response, error) {
+	auth := c.authenticator(req.URL)
+	if auth == nil {
+		return client.Do(req)
+	}
+
+	// Body is buffered, so that request can be sent again.
+	if req.Body != nil && req.GetBody == nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	if err := auth.Authenticate(client, req); err != nil {
+		return nil, fmt.Errorf("Auth: %w", err)
+	}
+	// Client adds cookies of jar to headers of req, retry gets new ones.
+	header := req.Header.Clone()
+	res, err := client.Do(req)
+	if err != nil || !auth.Expired(client, res) {
+		return res, err
+	}
+
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	retry := req.Clone(req.Context())
+	retry.Header = header
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if err := auth.Authenticate(client, retry); err != nil {
+		return nil, fmt.Errorf("Auth: %w", err)
+	}
+
+	return client.Do(retry)
+}
+
+// parseAuthParams parses comma separated auth-params of challenge.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimLeft(s, ", ") {
+		name, rest, found := strings.Cut(s, "=")
+		if !found {
+			break
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end > len(rest) {
+				end = len(rest)
+			}
+			value = strings.ReplaceAll(rest[1:end], `\`, "")
+			if end < len(rest) {
+				end++
+			}
+			s = rest[end:]
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		params[name] = value
+	}
+
+	return params
+}
+
+func hashHex(h hash.Hash, s string) string {
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,269 @@
+package hopper
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuthenticators(t *testing.T) {
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/basic", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	mux.HandleFunc("/digest", func(w http.ResponseWriter, r *http.Request) {
+		scheme, params, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		p := parseAuthParams(params)
+		ha1 := md5hex("user:realm:pass")
+		ha2 := md5hex(r.Method + ":" + p["uri"])
+		want := md5hex(strings.Join([]string{ha1, "abc", p["nc"], p["cnonce"], "auth", ha2}, ":"))
+		if scheme != "Digest" || p["response"] != want {
+			w.Header().Set("WWW-Authenticate", `Digest realm="realm", nonce="abc", qop="auth"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "id" || secret != "secret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, `{"access_token":"token","expires_in":3600}`)
+	})
+	mux.HandleFunc("/bearer", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			io.WriteString(w, `<form method="post" action="/login"><input type="hidden" name="csrf" value="123">
+				<input name="user"><input type="password" name="pass"><input type="submit" value="Go"></form>`)
+			return
+		}
+		if r.FormValue("csrf") != "123" || r.FormValue("user") != "user" || r.FormValue("pass") != "pass" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "ok"})
+		io.WriteString(w, `<div class="user menu">Logged in</div>`)
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "ok" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	host, _ := url.Parse(srv.URL)
+
+	tests := []struct {
+		Name string
+		Path string
+		Auth Authenticator
+	}{
+		{"Basic", "/basic", &BasicAuth{Username: "user", Password: "pass"}},
+		{"Digest", "/digest", &DigestAuth{Username: "user", Password: "pass"}},
+		{"ClientCredentials", "/bearer", &ClientCredentials{TokenURL: srv.URL + "/token", ClientID: "id", ClientSecret: "secret"}},
+		{"FormLogin", "/private", &FormLogin{
+			URL:    srv.URL + "/login",
+			Fields: map[string]string{"user": "user", "pass": "pass"},
+			Verify: "div.user",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			client := &Client{Client: &http.Client{}, Jar: &CookieJar{}}
+			client.Init()
+			client.Authenticate(host.Hostname(), test.Auth)
+
+			uri, _ := url.Parse(srv.URL + test.Path)
+			res, err := client.Do(http.MethodGet, uri, nil, http.Header{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != http.StatusOK || res.Request.URL.Path != test.Path {
+				t.Fatalf("client.Do = %d %s, want %d %s", res.StatusCode, res.Request.URL.Path, http.StatusOK, test.Path)
+			}
+		})
+	}
+}
+
+func TestFormLogin(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	session := ""
+	action := "/login"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodGet {
+			io.WriteString(w, `<form method="post" action="`+action+`"><input name="user"><input type="password" name="pass"></form>`)
+			return
+		}
+		logins++
+		session = strconv.Itoa(logins)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: session})
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != session {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	host, _ := url.Parse(srv.URL)
+
+	newClient := func() *Client {
+		client := &Client{Client: &http.Client{}, Jar: &CookieJar{}}
+		client.Init()
+		client.Authenticate(host.Hostname(), &FormLogin{URL: srv.URL + "/login", Fields: map[string]string{"user": "user", "pass": "pass"}})
+		return client
+	}
+
+	crawl := func(client *Client) []int {
+		var wg sync.WaitGroup
+		statuses := make([]int, 5)
+		for i := range statuses {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				uri, _ := url.Parse(srv.URL + "/private")
+				res, err := client.Do(http.MethodGet, uri, nil, http.Header{})
+				if err != nil {
+					return
+				}
+				res.Body.Close()
+				statuses[i] = res.StatusCode
+			}(i)
+		}
+		wg.Wait()
+		return statuses
+	}
+
+	t.Run("OncePerSession", func(t *testing.T) {
+		client := newClient()
+		for round := 1; round <= 2; round++ {
+			statuses := crawl(client)
+			for _, status := range statuses {
+				if status != http.StatusOK {
+					t.Fatalf("round %d statuses = %v, want all %d", round, statuses, http.StatusOK)
+				}
+			}
+			mu.Lock()
+			count := logins
+			// Server forgets session, all requests of next round find it expired.
+			session = "expired"
+			mu.Unlock()
+			if count != round {
+				t.Fatalf("round %d logins = %d, want %d", round, count, round)
+			}
+		}
+	})
+
+	t.Run("LoginPage", func(t *testing.T) {
+		client := newClient()
+		for _, path := range []string{"/private", "/login", "/login", "/private"} {
+			uri, _ := url.Parse(srv.URL + path)
+			res, err := client.Do(http.MethodGet, uri, nil, http.Header{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+		}
+
+		mu.Lock()
+		count := logins
+		mu.Unlock()
+		// Only one login since previous subtest, visits of login page
+		// don't expire session.
+		if count != 3 {
+			t.Fatalf("logins = %d, want 3", count)
+		}
+	})
+
+	t.Run("CrossHostAction", func(t *testing.T) {
+		mu.Lock()
+		action = "http://attacker.example/collect"
+		mu.Unlock()
+
+		uri, _ := url.Parse(srv.URL + "/private")
+		if _, err := newClient().Do(http.MethodGet, uri, nil, http.Header{}); !errors.Is(err, ErrCrossHostForm) {
+			t.Fatalf("err = %v, want %v", err, ErrCrossHostForm)
+		}
+	})
+}
+
+func TestFormLoginSessions(t *testing.T) {
+	var pending atomic.Int32
+	var overlapped atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/login" || r.Method != http.MethodGet {
+			return
+		}
+		// Login page waits for login of other session to start.
+		pending.Add(1)
+		defer pending.Add(-1)
+		deadline := time.Now().Add(time.Second)
+		for pending.Load() < 2 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if pending.Load() == 2 {
+			overlapped.Store(true)
+		}
+		io.WriteString(w, `<form method="post" action="/login"><input name="user"></form>`)
+	}))
+	defer srv.Close()
+	host, _ := url.Parse(srv.URL)
+
+	client := &Client{Client: &http.Client{}, Jar: &CookieJar{}}
+	client.Init()
+	client.Authenticate(host.Hostname(), &FormLogin{URL: srv.URL + "/login", Fields: map[string]string{"user": "user"}})
+
+	seed := &Request{}
+	seed.Init()
+	seed.Properties["AllowedDepth"] = 10
+
+	var wg sync.WaitGroup
+	for _, session := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(session string) {
+			defer wg.Done()
+			req, _ := seed.New(http.MethodGet, srv.URL+"/page")
+			req.Session = session
+			if res, err := client.DoRequest(req); err == nil {
+				res.Body.Close()
+			}
+		}(session)
+	}
+	wg.Wait()
+
+	if !overlapped.Load() {
+		t.Fatalf("logins of sessions were serialized, want them to run concurrently")
+	}
+}
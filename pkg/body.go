@@ -0,0 +1,120 @@
+package hopper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// Body is payload sent with request. It is kept in memory, so that
+// request can be retried and redirected with it.
+type Body struct {
+	Data        []byte
+	ContentType string
+}
+
+// MultipartFile is file part of multipart body.
+type MultipartFile struct {
+	Field       string
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+func BytesBody(contentType string, data []byte) *Body {
+	return &Body{Data: data, ContentType: contentType}
+}
+
+// FormBody encodes values as urlencoded form.
+func FormBody(values url.Values) *Body {
+	return &Body{Data: []byte(values.Encode()), ContentType: "application/x-www-form-urlencoded"}
+}
+
+// JSONBody encodes v as json.
+func JSONBody(v any) (*Body, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Body{Data: data, ContentType: "application/json"}, nil
+}
+
+// MultipartBody encodes values and files as multipart form, values are
+// written in order of their keys. Boundary is derived from content, so
+// that equal forms have equal bodies.
+func MultipartBody(values url.Values, files ...MultipartFile) (*Body, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%q=%q;", key, values[key])
+	}
+	for _, file := range files {
+		fmt.Fprintf(h, "%q;%q;%q;", file.Field, file.Name, file.ContentType)
+		h.Write(file.Data)
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.SetBoundary(hex.EncodeToString(h.Sum(nil))[:48]); err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		for _, value := range values[key] {
+			if err := w.WriteField(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, file := range files {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(file.Field), quoteEscaper.Replace(file.Name)))
+		header.Set("Content-Type", file.ContentType)
+		if file.ContentType == "" {
+			header.Set("Content-Type", "application/octet-stream")
+		}
+
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(file.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return &Body{Data: buf.Bytes(), ContentType: w.FormDataContentType()}, nil
+}
+
+// Reader returns new reader of body data.
+func (b *Body) Reader() io.Reader {
+	return bytes.NewReader(b.Data)
+}
+
+// Hash returns hex encoded sha256 of body data.
+func (b *Body) Hash() string {
+	sum := sha256.Sum256(b.Data)
+	return hex.EncodeToString(sum[:])
+}
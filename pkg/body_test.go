@@ -0,0 +1,90 @@
+package hopper
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRequestBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") == "application/json" {
+			var v map[string]string
+			json.NewDecoder(r.Body).Decode(&v)
+			io.WriteString(w, r.Method+" "+v["q"])
+			return
+		}
+		r.ParseMultipartForm(1 << 20)
+		io.WriteString(w, r.Method+" "+r.FormValue("q"))
+	}))
+	defer srv.Close()
+
+	parent := &Request{}
+	parent.Init()
+	parent.URL, _ = url.Parse(srv.URL)
+	parent.Properties["AllowedDepth"] = 10
+
+	newJSON := func() (*Request, error) {
+		body, err := JSONBody(map[string]string{"q": "json"})
+		if err != nil {
+			return nil, err
+		}
+		return parent.NewPost("/search", body)
+	}
+	newMultipart := func() (*Request, error) {
+		body, err := MultipartBody(url.Values{"q": {"multipart"}}, MultipartFile{Field: "file", Name: "a.txt", Data: []byte("a")})
+		if err != nil {
+			return nil, err
+		}
+		return parent.NewPost("/search", body)
+	}
+
+	tests := []struct {
+		Name string
+		New  func() (*Request, error)
+		Want string
+	}{
+		{"Query", func() (*Request, error) { return parent.NewForm("get", "/search", url.Values{"q": {"query"}}) }, "GET query"},
+		{"Form", func() (*Request, error) { return parent.NewForm("post", "/search", url.Values{"q": {"form"}}) }, "POST form"},
+		{"JSON", newJSON, "POST json"},
+		{"Multipart", newMultipart, "POST multipart"},
+	}
+
+	client := &Client{Client: &http.Client{}}
+	client.Init()
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			req, err := test.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := client.DoRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+
+			if string(got) != test.Want {
+				t.Fatalf("client.DoRequest = %s, want %s", got, test.Want)
+			}
+
+			other, _ := test.New()
+			if req.Key() != other.Key() {
+				t.Fatalf("Request.Key = %s, want %s", other.Key(), req.Key())
+			}
+		})
+	}
+
+	get, _ := parent.New(http.MethodGet, "/search")
+	post, _ := parent.NewPost("/search", FormBody(url.Values{"q": {"a"}}))
+	other, _ := parent.NewPost("/search", FormBody(url.Values{"q": {"b"}}))
+	if get.Key() != get.URL.String() || get.Key() == post.Key() || post.Key() == other.Key() {
+		t.Fatalf("Request.Key = %s, %s, %s, want distinct keys", get.Key(), post.Key(), other.Key())
+	}
+}
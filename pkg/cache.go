@@ -0,0 +1,498 @@
+package hopper
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerRequestTime  = "X-Hopper-Request-Time"
+	headerResponseTime = "X-Hopper-Response-Time"
+	headerVaryPrefix   = "X-Hopper-Vary-"
+)
+
+// DefaultCacheMaxSize limits size of bodies stored by CacheTransport.
+const DefaultCacheMaxSize = 4000000
+
+// heuristicFraction is fraction of time since Last-Modified used as
+// freshness lifetime of responses without explicit expiration.
+const heuristicFraction = 0.1
+
+// cacheableStatus are status codes which are heuristically cacheable.
+var cacheableStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// Cache stores serialised responses.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, data []byte)
+	Delete(key string)
+}
+
+type MemoryCache struct {
+	entries sync.Map
+}
+
+func (mc *MemoryCache) Get(key string) ([]byte, bool) {
+	data, exists := mc.entries.Load(key)
+	if !exists {
+		return nil, false
+	}
+	return data.([]byte), true
+}
+
+func (mc *MemoryCache) Set(key string, data []byte) {
+	mc.entries.Store(key, data)
+}
+
+func (mc *MemoryCache) Delete(key string) {
+	mc.entries.Delete(key)
+}
+
+// DiskCache stores each response in file of Dir named by hash of its key.
+type DiskCache struct {
+	Dir string
+}
+
+func (dc *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dc.Dir, hex.EncodeToString(sum[:]))
+}
+
+func (dc *DiskCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(dc.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (dc *DiskCache) Set(key string, data []byte) {
+	if err := os.MkdirAll(dc.Dir, 0o755); err != nil {
+		return
+	}
+
+	// Write to temporary file first so that readers never see partial entry.
+	tmp, err := os.CreateTemp(dc.Dir, "tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	os.Rename(tmp.Name(), dc.path(key))
+}
+
+func (dc *DiskCache) Delete(key string) {
+	os.Remove(dc.path(key))
+}
+
+// CacheTransport is private HTTP cache following RFC 9111. Fresh responses
+// are served from Cache, stale ones are revalidated when they have
+// validators. ForceCache serves every cached response regardless of its
+// freshness, which is useful when developing scrapers.
+//
+// Bodies are stored once they were read whole. Bodies larger than MaxSize,
+// or ContentLength of crawled request if it is not set, are passed
+// through uncached.
+type CacheTransport struct {
+	Transport  http.RoundTripper
+	Cache      Cache
+	ForceCache bool
+	MaxSize    int64
+}
+
+func (t *CacheTransport) transport() http.RoundTripper {
+	if t.Transport == nil {
+		return http.DefaultTransport
+	}
+	return t.Transport
+}
+
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.String()
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		res, err := t.transport().RoundTrip(req)
+		// Unsafe methods invalidate cached representation of target.
+		if err == nil && req.Method != http.MethodOptions && res.StatusCode < 400 {
+			t.Cache.Delete(http.MethodGet + " " + req.URL.String())
+			t.Cache.Delete(http.MethodHead + " " + req.URL.String())
+		}
+		return res, err
+	}
+
+	// Partial content is not cached.
+	if req.Header.Get("Range") != "" {
+		return t.transport().RoundTrip(req)
+	}
+
+	reqControl := parseCacheControl(req.Header)
+	if _, noStore := reqControl["no-store"]; noStore && !t.ForceCache {
+		return t.transport().RoundTrip(req)
+	}
+
+	cached := t.load(key, req)
+	if cached != nil {
+		if t.ForceCache || (t.fresh(cached, reqControl) && !noCache(cached)) {
+			return t.serve(cached, req), nil
+		}
+		if etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified"); etag != "" || lastModified != "" {
+			return t.revalidate(key, req, cached, etag, lastModified)
+		}
+	}
+
+	return t.fetch(key, req)
+}
+
+// fetch sends request to origin and stores response if it is cacheable.
+func (t *CacheTransport) fetch(key string, req *http.Request) (*http.Response, error) {
+	requestTime := time.Now()
+	res, err := t.transport().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.storable(req, res) {
+		t.store(key, req, res, requestTime)
+	}
+
+	return res, nil
+}
+
+// revalidate sends conditional request for stale cached response.
+func (t *CacheTransport) revalidate(key string, req *http.Request, cached *http.Response, etag string, lastModified string) (*http.Response, error) {
+	// Conditional requests of caller are answered by origin itself.
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.fetch(key, req)
+	}
+
+	conditional := req.Clone(req.Context())
+	if etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	requestTime := time.Now()
+	res, err := t.transport().RoundTrip(conditional)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusNotModified {
+		if t.storable(req, res) {
+			t.store(key, req, res, requestTime)
+		}
+		return res, nil
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	// Update stored response with headers of 304 response.
+	for name, values := range res.Header {
+		cached.Header[name] = values
+	}
+	body, err := io.ReadAll(cached.Body)
+	cached.Body.Close()
+	cached.Body = io.NopCloser(bytes.NewReader(body))
+	if err == nil {
+		t.save(key, req, cached, body, requestTime)
+	}
+	if reloaded := t.load(key, req); reloaded != nil {
+		cached = reloaded
+	}
+
+	return t.serve(cached, req), nil
+}
+
+// serve prepares cached response for request, answering matching
+// conditional requests with 304.
+func (t *CacheTransport) serve(cached *http.Response, req *http.Request) *http.Response {
+	cached.Request = req
+	cached.Header.Set("Age", strconv.Itoa(int(currentAge(cached).Seconds())))
+	for name := range cached.Header {
+		if strings.HasPrefix(name, "X-Hopper-") {
+			cached.Header.Del(name)
+		}
+	}
+
+	if etag := req.Header.Get("If-None-Match"); etag != "" && etag == cached.Header.Get("ETag") {
+		cached.Body.Close()
+		cached.StatusCode = http.StatusNotModified
+		cached.Status = "304 " + http.StatusText(http.StatusNotModified)
+		cached.Body = http.NoBody
+		cached.ContentLength = 0
+	}
+
+	return cached
+}
+
+// load returns stored response for key if it matches Vary headers of req.
+func (t *CacheTransport) load(key string, req *http.Request) *http.Response {
+	data, exists := t.Cache.Get(key)
+	if !exists {
+		return nil
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		t.Cache.Delete(key)
+		return nil
+	}
+
+	for _, name := range varyHeaders(res.Header) {
+		if res.Header.Get(headerVaryPrefix+name) != req.Header.Get(name) {
+			res.Body.Close()
+			return nil
+		}
+	}
+
+	return res
+}
+
+// store replaces body of response with one which saves response once it
+// is read whole, unless it exceeds size limit.
+func (t *CacheTransport) store(key string, req *http.Request, res *http.Response, requestTime time.Time) {
+	if res.Body == nil || res.Body == http.NoBody {
+		t.save(key, req, res, []byte{}, requestTime)
+		return
+	}
+
+	// Headers are taken before client changes them, e.g. by decoding body.
+	received := *res
+	received.Header = res.Header.Clone()
+	res.Body = &cachingBody{ReadCloser: res.Body, limit: t.maxSize(req), done: func(body []byte) {
+		t.save(key, req, &received, body, requestTime)
+	}}
+}
+
+// maxSize returns limit of stored body for request.
+func (t *CacheTransport) maxSize(req *http.Request) int64 {
+	if t.MaxSize > 0 {
+		return t.MaxSize
+	}
+	if r, ok := req.Context().Value(requestContextKey{}).(*Request); ok {
+		if limit, ok := r.Properties["ContentLength"].(int64); ok && limit > 0 {
+			return limit
+		}
+	}
+	return DefaultCacheMaxSize
+}
+
+// save serialises response with body, times and values of Vary headers.
+func (t *CacheTransport) save(key string, req *http.Request, res *http.Response, body []byte, requestTime time.Time) {
+	stored := *res
+	stored.Header = res.Header.Clone()
+	stored.Body = io.NopCloser(bytes.NewReader(body))
+	stored.ContentLength = int64(len(body))
+	stored.TransferEncoding = nil
+	stored.Header.Del("Transfer-Encoding")
+	stored.Header.Set(headerRequestTime, requestTime.UTC().Format(time.RFC3339Nano))
+	stored.Header.Set(headerResponseTime, time.Now().UTC().Format(time.RFC3339Nano))
+	for _, name := range varyHeaders(res.Header) {
+		stored.Header.Set(headerVaryPrefix+name, req.Header.Get(name))
+	}
+
+	data, err := httputil.DumpResponse(&stored, true)
+	if err != nil {
+		return
+	}
+	t.Cache.Set(key, data)
+}
+
+// cachingBody buffers body while it is read and passes it to done once
+// it was read whole, unless it exceeded limit.
+type cachingBody struct {
+	io.ReadCloser
+
+	buf      bytes.Buffer
+	limit    int64
+	exceeded bool
+	done     func(body []byte)
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.exceeded {
+		if int64(b.buf.Len()+n) > b.limit {
+			b.exceeded = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !b.exceeded && b.done != nil {
+		b.done(b.buf.Bytes())
+		b.done = nil
+	}
+	return n, err
+}
+
+// storable reports whether response can be stored in private cache.
+func (t *CacheTransport) storable(req *http.Request, res *http.Response) bool {
+	if t.ForceCache {
+		return res.StatusCode < 500
+	}
+
+	control := parseCacheControl(res.Header)
+	if _, noStore := control["no-store"]; noStore {
+		return false
+	}
+	for _, name := range varyHeaders(res.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	_, maxAge := control["max-age"]
+	_, public := control["public"]
+	explicit := maxAge || public || res.Header.Get("Expires") != ""
+
+	return explicit || (cacheableStatus[res.StatusCode] && res.Header.Get("Last-Modified") != "")
+}
+
+// fresh reports whether age of response is within its freshness lifetime
+// and limits of request Cache-Control.
+func (t *CacheTransport) fresh(res *http.Response, reqControl map[string]string) bool {
+	if _, noCache := reqControl["no-cache"]; noCache {
+		return false
+	}
+
+	lifetime := freshnessLifetime(res)
+	age := currentAge(res)
+
+	if maxAge, ok := parseSeconds(reqControl, "max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := parseSeconds(reqControl, "min-fresh"); ok {
+		age += minFresh
+	}
+	if maxStale, exists := reqControl["max-stale"]; exists {
+		if maxStale == "" {
+			return true
+		}
+		if stale, ok := parseSeconds(reqControl, "max-stale"); ok {
+			lifetime += stale
+		}
+	}
+
+	return age < lifetime
+}
+
+// noCache reports whether response must be revalidated before each use.
+func noCache(res *http.Response) bool {
+	_, noCache := parseCacheControl(res.Header)["no-cache"]
+	return noCache
+}
+
+func freshnessLifetime(res *http.Response) time.Duration {
+	control := parseCacheControl(res.Header)
+	if maxAge, ok := parseSeconds(control, "max-age"); ok {
+		return maxAge
+	}
+
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		date = storedTime(res, headerResponseTime)
+	}
+
+	if expires := res.Header.Get("Expires"); expires != "" {
+		expiresTime, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return expiresTime.Sub(date)
+	}
+
+	if lastModified, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil && cacheableStatus[res.StatusCode] {
+		return time.Duration(float64(date.Sub(lastModified)) * heuristicFraction)
+	}
+
+	return 0
+}
+
+// currentAge computes age of stored response as described in RFC 9111
+// section 4.2.3.
+func currentAge(res *http.Response) time.Duration {
+	requestTime := storedTime(res, headerRequestTime)
+	responseTime := storedTime(res, headerResponseTime)
+
+	apparentAge := time.Duration(0)
+	if date, err := http.ParseTime(res.Header.Get("Date")); err == nil && responseTime.After(date) {
+		apparentAge = responseTime.Sub(date)
+	}
+
+	ageValue, _ := strconv.Atoi(res.Header.Get("Age"))
+	correctedAge := time.Duration(ageValue)*time.Second + responseTime.Sub(requestTime)
+	if apparentAge > correctedAge {
+		correctedAge = apparentAge
+	}
+
+	return correctedAge + time.Since(responseTime)
+}
+
+func storedTime(res *http.Response, header string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, res.Header.Get(header))
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
+
+func parseCacheControl(header http.Header) map[string]string {
+	control := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				control[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+
+	return control
+}
+
+func parseSeconds(control map[string]string, directive string) (time.Duration, bool) {
+	value, exists := control[directive]
+	if !exists {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+func varyHeaders(header http.Header) []string {
+	names := []string{}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
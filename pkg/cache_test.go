@@ -0,0 +1,127 @@
+package hopper
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCacheTransport(t *testing.T) {
+	var hits int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/stale":
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		io.WriteString(w, "body of "+r.URL.Path)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		Name       string
+		Path       string
+		Languages  []string
+		ForceCache bool
+		Hits       int32
+	}{
+		{"Fresh", "/fresh", []string{"", ""}, false, 1},
+		{"Revalidated", "/stale", []string{"", ""}, false, 2},
+		{"NoStore", "/nostore", []string{"", ""}, false, 2},
+		{"VaryMismatch", "/vary", []string{"en", "pl"}, false, 2},
+		{"VaryMatch", "/vary", []string{"en", "en"}, false, 1},
+		{"ForceCache", "/stale", []string{"", ""}, true, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			client := &Client{Client: &http.Client{}, Cache: &MemoryCache{}, ForceCache: test.ForceCache}
+			client.Init()
+
+			uri, _ := url.Parse(srv.URL + test.Path)
+			for _, language := range test.Languages {
+				headers := http.Header{}
+				if language != "" {
+					headers.Set("Accept-Language", language)
+				}
+
+				res, err := client.Do(http.MethodGet, uri, nil, headers)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(res.Body)
+				res.Body.Close()
+
+				if res.StatusCode != http.StatusOK || string(body) != "body of "+test.Path {
+					t.Fatalf("client.Do = %d %q, want %d %q", res.StatusCode, body, http.StatusOK, "body of "+test.Path)
+				}
+			}
+
+			if got := atomic.LoadInt32(&hits); got != test.Hits {
+				t.Fatalf("server hits = %d, want %d", got, test.Hits)
+			}
+		})
+	}
+}
+
+func TestCacheTransportMaxSize(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, strings.Repeat("a", len(r.URL.Path)*8))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		Name string
+		Path string
+		Hits int32
+	}{
+		{"WithinLimit", "/ab", 1},
+		{"OverLimit", "/abc", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			client := &http.Client{Transport: &CacheTransport{Cache: &MemoryCache{}, MaxSize: 24}}
+
+			for i := 0; i < 2; i++ {
+				res, err := client.Get(srv.URL + test.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(res.Body)
+				res.Body.Close()
+
+				if len(body) != len(test.Path)*8 {
+					t.Fatalf("body = %d bytes, want %d", len(body), len(test.Path)*8)
+				}
+			}
+
+			if got := atomic.LoadInt32(&hits); got != test.Hits {
+				t.Fatalf("server hits = %d, want %d", got, test.Hits)
+			}
+		})
+	}
+}
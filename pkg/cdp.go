@@ -0,0 +1,371 @@
+package hopper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	DefaultCDPEndpoint = "http://127.0.0.1:9222"
+	DefaultCDPTimeout  = 30 * time.Second
+
+	// cdpCloseTimeout limits closing of tab.
+	cdpCloseTimeout = 5 * time.Second
+)
+
+var (
+	ErrCDP        = errors.New("DevTools protocol error")
+	ErrRenderFail = errors.New("Page failed to render")
+)
+
+// CDPFetcher renders pages in headless browser it controls over Chrome
+// DevTools Protocol, and returns document as it is after scripts ran.
+// Each request is rendered in new tab. Browser must accept connections
+// from origin of Endpoint, e.g. be started with --remote-allow-origins.
+//
+// Only headers and User-Agent of crawler are sent by browser. Cookie jar,
+// proxies, authenticators, profiles, validators and cache of crawler are
+// not used, browser handles cookies and redirects on its own.
+type CDPFetcher struct {
+	// Endpoint is http address of browser's remote debugging port.
+	Endpoint string
+	// Timeout limits rendering of page, when context has no deadline.
+	Timeout time.Duration
+	// Wait is time scripts are given to run after page has loaded.
+	Wait time.Duration
+	// Headers are sent with each request, unless request sets them.
+	Headers http.Header
+}
+
+type cdpTarget struct {
+	ID                   string `json:"id"`
+	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+}
+
+type cdpCommand struct {
+	ID     int    `json:"id"`
+	Method string `json:"method"`
+	Params any    `json:"params,omitempty"`
+}
+
+type cdpMessage struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// cdpDocument is response of document received by page.
+type cdpDocument struct {
+	RequestID string `json:"requestId"`
+	Type      string `json:"type"`
+	Response  struct {
+		URL     string            `json:"url"`
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers"`
+	} `json:"response"`
+}
+
+// Fetch navigates new tab of browser to request and returns rendered
+// document. Status and headers are those of document response, body is
+// serialized DOM. Only GET requests can be rendered.
+func (cf *CDPFetcher) Fetch(ctx context.Context, req *Request) (*Response, error) {
+	if req.Method != "" && req.Method != http.MethodGet {
+		return nil, fmt.Errorf("Method %s can't be rendered: %w", req.Method, ErrRenderFail)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := cf.Timeout
+		if timeout == 0 {
+			timeout = DefaultCDPTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	target, err := cf.newTarget(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cf.closeTarget(target)
+
+	session, err := dialCDP(ctx, cf.endpoint(), target.WebSocketDebuggerURL)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	return cf.render(ctx, session, req)
+}
+
+func (cf *CDPFetcher) render(ctx context.Context, session *cdpSession, req *Request) (*Response, error) {
+	headers := map[string]string{}
+	for k, v := range cf.Headers {
+		headers[k] = strings.Join(v, ", ")
+	}
+	for k, v := range req.Headers {
+		headers[k] = strings.Join(v, ", ")
+	}
+	// Browser negotiates encodings of its own.
+	delete(headers, "Accept-Encoding")
+
+	// User-Agent replaces one of browser, rather than being added to it.
+	userAgent, _ := req.Properties["UserAgent"].(string)
+	if agent, exists := headers["User-Agent"]; exists {
+		userAgent = agent
+		delete(headers, "User-Agent")
+	}
+
+	commands := []cdpCommand{
+		{Method: "Page.enable"},
+		{Method: "Network.enable"},
+		{Method: "Network.setExtraHTTPHeaders", Params: map[string]any{"headers": headers}},
+	}
+	if userAgent != "" {
+		commands = append(commands, cdpCommand{Method: "Network.setUserAgentOverride", Params: map[string]any{"userAgent": userAgent}})
+	}
+	for _, command := range commands {
+		if err := session.call(command.Method, command.Params, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var navigation struct {
+		LoaderID  string `json:"loaderId"`
+		ErrorText string `json:"errorText"`
+	}
+	if err := session.call("Page.navigate", map[string]any{"url": req.URL.String()}, &navigation); err != nil {
+		return nil, err
+	}
+	if navigation.ErrorText != "" {
+		return nil, fmt.Errorf("%s: %w", navigation.ErrorText, ErrRenderFail)
+	}
+
+	// Document response is received before page loads.
+	var document *cdpDocument
+	for {
+		event, err := session.next()
+		if err != nil {
+			return nil, err
+		}
+		if event.Method == "Page.loadEventFired" {
+			break
+		}
+		if event.Method != "Network.responseReceived" {
+			continue
+		}
+
+		received := &cdpDocument{}
+		if err := json.Unmarshal(event.Params, received); err != nil {
+			return nil, err
+		}
+		if received.Type == "Document" && received.RequestID == navigation.LoaderID {
+			document = received
+		}
+	}
+	if document == nil {
+		return nil, fmt.Errorf("No document response: %w", ErrRenderFail)
+	}
+
+	if cf.Wait > 0 {
+		select {
+		case <-time.After(cf.Wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	var evaluation struct {
+		Result struct {
+			Value string `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	expression := map[string]any{"expression": "document.documentElement.outerHTML", "returnByValue": true}
+	if err := session.call("Runtime.evaluate", expression, &evaluation); err != nil {
+		return nil, err
+	}
+	if evaluation.ExceptionDetails != nil {
+		return nil, fmt.Errorf("%s: %w", evaluation.ExceptionDetails.Text, ErrRenderFail)
+	}
+
+	return renderedResponse(req, document, evaluation.Result.Value)
+}
+
+// renderedResponse creates response of rendered document.
+func renderedResponse(req *Request, document *cdpDocument, dom string) (*Response, error) {
+	header := http.Header{}
+	for k, v := range document.Response.Headers {
+		// Browser joins repeated headers with newlines.
+		for _, value := range strings.Split(v, "\n") {
+			header.Add(k, value)
+		}
+	}
+	// Body is serialized DOM, not payload which was received.
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	header.Set("Content-Type", "text/html; charset=utf-8")
+
+	final, err := url.Parse(document.Response.URL)
+	if err != nil || !final.IsAbs() {
+		final = req.URL
+	}
+
+	body := io.NopCloser(strings.NewReader("<!DOCTYPE html>" + dom))
+	httpRes := &http.Response{
+		StatusCode: document.Response.Status,
+		Header:     header,
+		Body:       body,
+		Request:    &http.Request{Method: http.MethodGet, URL: final},
+	}
+	return NewResponse(httpRes, req.Properties, req)
+}
+
+func (cf *CDPFetcher) endpoint() string {
+	if cf.Endpoint == "" {
+		return DefaultCDPEndpoint
+	}
+	return strings.TrimSuffix(cf.Endpoint, "/")
+}
+
+// newTarget opens blank tab of browser.
+func (cf *CDPFetcher) newTarget(ctx context.Context) (*cdpTarget, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, cf.endpoint()+"/json/new?about:blank", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Opening tab responded with %d: %w", res.StatusCode, ErrCDP)
+	}
+
+	target := &cdpTarget{}
+	if err := json.NewDecoder(res.Body).Decode(target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// closeTarget closes tab within timeout of its own, as context it was
+// rendered with may be done already.
+func (cf *CDPFetcher) closeTarget(target *cdpTarget) {
+	ctx, cancel := context.WithTimeout(context.Background(), cdpCloseTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cf.endpoint()+"/json/close/"+url.PathEscape(target.ID), nil)
+	if err != nil {
+		return
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	drainBody(res.Body)
+}
+
+// cdpSession sends commands to target and buffers events received while
+// waiting for their results.
+type cdpSession struct {
+	conn   *websocket.Conn
+	done   chan struct{}
+	id     int
+	events []*cdpMessage
+}
+
+func dialCDP(ctx context.Context, origin string, uri string) (*cdpSession, error) {
+	config, err := websocket.NewConfig(uri, origin)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	config.Dialer = &net.Dialer{Deadline: deadline}
+
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(deadline)
+
+	// Canceled context unblocks pending reads.
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return &cdpSession{conn: conn, done: done}, nil
+}
+
+func (s *cdpSession) Close() error {
+	close(s.done)
+	return s.conn.Close()
+}
+
+// call sends command and decodes its result into result, if it is set.
+func (s *cdpSession) call(method string, params any, result any) error {
+	s.id++
+	if err := websocket.JSON.Send(s.conn, cdpCommand{ID: s.id, Method: method, Params: params}); err != nil {
+		return err
+	}
+
+	for {
+		message := &cdpMessage{}
+		if err := websocket.JSON.Receive(s.conn, message); err != nil {
+			return err
+		}
+		if message.ID != s.id {
+			if message.Method != "" {
+				s.events = append(s.events, message)
+			}
+			continue
+		}
+
+		if message.Error != nil {
+			return fmt.Errorf("%s: %s: %w", method, message.Error.Message, ErrCDP)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(message.Result, result)
+	}
+}
+
+// next returns next event of target.
+func (s *cdpSession) next() (*cdpMessage, error) {
+	for len(s.events) == 0 {
+		message := &cdpMessage{}
+		if err := websocket.JSON.Receive(s.conn, message); err != nil {
+			return nil, err
+		}
+		if message.Method != "" {
+			s.events = append(s.events, message)
+		}
+	}
+
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
@@ -0,0 +1,153 @@
+package hopper
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+// fakeBrowser serves DevTools endpoints of browser which renders every
+// page into document linking /rendered.
+func fakeBrowser() (*httptest.Server, *sync.Map) {
+	seen := &sync.Map{}
+	var srv *httptest.Server
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json/new", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		ws := "ws" + strings.TrimPrefix(srv.URL, "http") + "/devtools/page/T1"
+		json.NewEncoder(w).Encode(cdpTarget{ID: "T1", WebSocketDebuggerURL: ws})
+	})
+	mux.HandleFunc("/json/close/", func(w http.ResponseWriter, r *http.Request) {
+		seen.Store("closed", strings.TrimPrefix(r.URL.Path, "/json/close/"))
+		io.WriteString(w, "Target is closing")
+	})
+	mux.Handle("/devtools/page/T1", websocket.Handler(func(conn *websocket.Conn) {
+		for {
+			var command struct {
+				ID     int             `json:"id"`
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}
+			if err := websocket.JSON.Receive(conn, &command); err != nil {
+				return
+			}
+			seen.LoadOrStore(command.Method, string(command.Params))
+
+			result := map[string]any{}
+			var events []map[string]any
+			switch command.Method {
+			case "Page.navigate":
+				result = map[string]any{"frameId": "F1", "loaderId": "L1"}
+				response := func(requestID string, kind string, status int) map[string]any {
+					return map[string]any{"method": "Network.responseReceived", "params": map[string]any{
+						"requestId": requestID, "type": kind,
+						"response": map[string]any{"url": "http://example.com/page", "status": status, "headers": map[string]string{"X-Served": "origin", "Content-Encoding": "gzip"}},
+					}}
+				}
+				events = append(events, response("R1", "Image", 404), response("L1", "Document", 200), map[string]any{"method": "Page.loadEventFired", "params": map[string]any{}})
+			case "Runtime.evaluate":
+				result = map[string]any{"result": map[string]any{"type": "string", "value": `<html><head></head><body><a href="/rendered">Rendered</a></body></html>`}}
+			}
+
+			// Events arrive before result of command which caused them.
+			for _, event := range events {
+				websocket.JSON.Send(conn, event)
+			}
+			websocket.JSON.Send(conn, map[string]any{"id": command.ID, "result": result})
+		}
+	}))
+
+	srv = httptest.NewServer(mux)
+	return srv, seen
+}
+
+func TestCDPFetcher(t *testing.T) {
+	browser, seen := fakeBrowser()
+	defer browser.Close()
+
+	tests := []struct {
+		Name    string
+		Fetcher string
+		Err     error
+	}{
+		{"Rendered", "browser", nil},
+		{"Unknown", "missing", ErrUnknownFetcher},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			crawler := Crawler{Fetchers: map[string]Fetcher{"browser": &CDPFetcher{Endpoint: browser.URL}}, UserAgent: "hopper-test", AllowedDepth: 1, Delay: TestDelay}
+			crawler.Init()
+
+			var mu sync.Mutex
+			var visitErr error
+			var res *Response
+			pushed := []string{}
+			crawler.OnError(func(r *Request, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				// Links of rendered page are beyond allowed depth.
+				if !errors.Is(err, ErrFiltered) {
+					visitErr = err
+				}
+			})
+			crawler.OnResponse(func(r *Response) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if res == nil {
+					res = r
+				}
+				return nil
+			})
+			crawler.OnPush(func(r *Request) error {
+				mu.Lock()
+				defer mu.Unlock()
+				pushed = append(pushed, r.URL.Path+" "+r.Fetcher)
+				return nil
+			})
+
+			root := crawler.Root()
+			seed, _ := root.New(http.MethodGet, "http://example.com/page")
+			seed.Fetcher = test.Fetcher
+			seed.Headers.Set("X-Token", "secret")
+			crawler.RunRequests(seed)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !errors.Is(visitErr, test.Err) {
+				t.Fatalf("err = %v, want %v", visitErr, test.Err)
+			}
+			if test.Err != nil {
+				return
+			}
+
+			if res.StatusCode != http.StatusOK || res.Headers.Get("X-Served") != "origin" || res.Headers.Get("Content-Encoding") != "" || !strings.HasPrefix(res.Headers.Get("Content-Type"), "text/html") {
+				t.Fatalf("Response = %d %v, want rendered document", res.StatusCode, res.Headers)
+			}
+			if len(pushed) != 2 || pushed[1] != "/rendered browser" {
+				t.Fatalf("pushed = %v, want rendered link fetched by browser", pushed)
+			}
+			if headers, _ := seen.Load("Network.setExtraHTTPHeaders"); !strings.Contains(headers.(string), "secret") || strings.Contains(headers.(string), "User-Agent") {
+				t.Fatalf("extra headers = %s, want request headers", headers)
+			}
+			if agent, _ := seen.Load("Network.setUserAgentOverride"); agent != `{"userAgent":"hopper-test"}` {
+				t.Fatalf("user agent override = %v, want crawler User-Agent", agent)
+			}
+			if closed, _ := seen.Load("closed"); closed != "T1" {
+				t.Fatalf("closed target = %v, want T1", closed)
+			}
+		})
+	}
+}
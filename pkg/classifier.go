@@ -0,0 +1,242 @@
+package hopper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+const DefaultAnchorWeight = 0.5
+
+var ErrIrrelevant = errors.New("Irrelevant request")
+
+// RelevanceClassifier scores text between 0 and 1 by its relevance
+// to crawled topic.
+type RelevanceClassifier interface {
+	Relevance(text string) float64
+}
+
+// KeywordClassifier scores text by fraction of keyword weights it contains.
+type KeywordClassifier struct {
+	Keywords map[string]float64
+}
+
+func (kc *KeywordClassifier) Relevance(text string) float64 {
+	tokens := map[string]bool{}
+	for _, token := range Tokenize(text) {
+		tokens[token] = true
+	}
+
+	total, found := 0.0, 0.0
+	for keyword, weight := range kc.Keywords {
+		total += weight
+		if tokens[strings.ToLower(keyword)] {
+			found += weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+
+	return found / total
+}
+
+// TFIDFClassifier scores text by cosine similarity of its TF-IDF vector
+// to centroid of example pages it was trained on.
+type TFIDFClassifier struct {
+	sync.RWMutex
+
+	documents int
+	df        map[string]int
+	examples  []map[string]float64
+	centroid  map[string]float64
+}
+
+// Train adds relevant examples and recomputes topic centroid.
+func (tc *TFIDFClassifier) Train(examples ...string) {
+	tc.Lock()
+	defer tc.Unlock()
+
+	if tc.df == nil {
+		tc.df = map[string]int{}
+	}
+
+	for _, example := range examples {
+		tf := termFrequencies(Tokenize(example))
+		for term := range tf {
+			tc.df[term]++
+		}
+		tc.examples = append(tc.examples, tf)
+		tc.documents++
+	}
+
+	tc.centroid = map[string]float64{}
+	for _, tf := range tc.examples {
+		for term, weight := range tc.weigh(tf) {
+			tc.centroid[term] += weight / float64(len(tc.examples))
+		}
+	}
+}
+
+func (tc *TFIDFClassifier) Relevance(text string) float64 {
+	tc.RLock()
+	defer tc.RUnlock()
+
+	if len(tc.centroid) == 0 {
+		return 0
+	}
+
+	return cosine(tc.weigh(termFrequencies(Tokenize(text))), tc.centroid)
+}
+
+func (tc *TFIDFClassifier) weigh(tf map[string]float64) map[string]float64 {
+	weights := make(map[string]float64, len(tf))
+	for term, freq := range tf {
+		idf := math.Log(float64(tc.documents+1)/float64(tc.df[term]+1)) + 1
+		weights[term] = freq * idf
+	}
+
+	return weights
+}
+
+// NaiveBayesClassifier is multinomial naive Bayes classifier trained on
+// relevant and irrelevant example pages. Relevance is posterior
+// probability of text being relevant.
+type NaiveBayesClassifier struct {
+	sync.RWMutex
+
+	docs   [2]int
+	tokens [2]int
+	counts [2]map[string]int
+	vocab  map[string]bool
+}
+
+// Train adds example text of relevant or irrelevant class.
+func (nb *NaiveBayesClassifier) Train(text string, relevant bool) {
+	nb.Lock()
+	defer nb.Unlock()
+
+	if nb.vocab == nil {
+		nb.vocab = map[string]bool{}
+		nb.counts = [2]map[string]int{{}, {}}
+	}
+
+	class := 0
+	if relevant {
+		class = 1
+	}
+
+	nb.docs[class]++
+	for _, token := range Tokenize(text) {
+		nb.counts[class][token]++
+		nb.tokens[class]++
+		nb.vocab[token] = true
+	}
+}
+
+func (nb *NaiveBayesClassifier) Relevance(text string) float64 {
+	nb.RLock()
+	defer nb.RUnlock()
+
+	if nb.docs[0] == 0 || nb.docs[1] == 0 {
+		return 0
+	}
+
+	var logProb [2]float64
+	total := float64(nb.docs[0] + nb.docs[1])
+	vocab := float64(len(nb.vocab))
+	for class := range logProb {
+		logProb[class] = math.Log(float64(nb.docs[class]) / total)
+		for _, token := range Tokenize(text) {
+			if !nb.vocab[token] {
+				continue
+			}
+			count := float64(nb.counts[class][token])
+			logProb[class] += math.Log((count + 1) / (float64(nb.tokens[class]) + vocab))
+		}
+	}
+
+	return 1 / (1 + math.Exp(logProb[0]-logProb[1]))
+}
+
+type FocusedMiddleware struct {
+	Classifier RelevanceClassifier
+	Threshold  float64
+	// AnchorWeight is share of anchor context in priority of discovered
+	// link, rest is inherited from relevance of page it was found on.
+	AnchorWeight float64
+}
+
+// Focused turns crawler into focused crawler. Relevance of each response
+// is stored on its request and inherited by discovered links, which are
+// prioritized by it combined with relevance of their anchor context.
+// Links scoring below threshold are not pushed.
+func Focused(crawler *Crawler, classifier RelevanceClassifier, threshold float64) *FocusedMiddleware {
+	fm := &FocusedMiddleware{Classifier: classifier, Threshold: threshold, AnchorWeight: DefaultAnchorWeight}
+
+	crawler.OnResponse(func(r *Response) error {
+		if r.NotModified {
+			return nil
+		}
+
+		body, err := r.Bytes()
+		if err != nil {
+			return fmt.Errorf("Focused: %w", err)
+		}
+
+		r.Request.Relevance = fm.Classifier.Relevance(ExtractText(body))
+		return nil
+	})
+
+	crawler.OnPush(func(r *Request) error {
+		if r.Depth == 0 {
+			return nil
+		}
+
+		score := fm.Score(r)
+		if score < fm.Threshold {
+			return fmt.Errorf("Focused: score %.2f of %s: %w", score, r.URL, ErrIrrelevant)
+		}
+		r.Priority = score
+
+		return nil
+	})
+
+	return fm
+}
+
+// Score combines relevance inherited from parent with relevance of
+// anchor text and url words of request.
+func (fm *FocusedMiddleware) Score(r *Request) float64 {
+	context := r.Anchor + " " + strings.NewReplacer("/", " ", "-", " ", "_", " ").Replace(r.URL.Path)
+	anchor := fm.Classifier.Relevance(context)
+
+	return (1-fm.AnchorWeight)*r.Relevance + fm.AnchorWeight*anchor
+}
+
+func termFrequencies(tokens []string) map[string]float64 {
+	tf := map[string]float64{}
+	for _, token := range tokens {
+		tf[token] += 1 / float64(len(tokens))
+	}
+
+	return tf
+}
+
+func cosine(a, b map[string]float64) float64 {
+	dot, na, nb := 0.0, 0.0, 0.0
+	for term, weight := range a {
+		dot += weight * b[term]
+		na += weight * weight
+	}
+	for _, weight := range b {
+		nb += weight * weight
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
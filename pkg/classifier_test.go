@@ -0,0 +1,103 @@
+package hopper
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestClassifiers(t *testing.T) {
+	relevant := []string{
+		"golang concurrency with goroutines and channels",
+		"writing a web crawler in golang using goroutines",
+		"golang http client and channels for crawling",
+	}
+	irrelevant := []string{
+		"baking sourdough bread with flour and water",
+		"tomato pasta recipe with garlic and basil",
+		"how to grow tomatoes in your garden",
+	}
+
+	tfidf := &TFIDFClassifier{}
+	tfidf.Train(relevant...)
+
+	bayes := &NaiveBayesClassifier{}
+	for _, text := range relevant {
+		bayes.Train(text, true)
+	}
+	for _, text := range irrelevant {
+		bayes.Train(text, false)
+	}
+
+	classifiers := map[string]RelevanceClassifier{
+		"Keyword":    &KeywordClassifier{Keywords: map[string]float64{"golang": 2, "crawler": 1, "goroutines": 1}},
+		"TFIDF":      tfidf,
+		"NaiveBayes": bayes,
+	}
+
+	for name, classifier := range classifiers {
+		t.Run(name, func(t *testing.T) {
+			on := classifier.Relevance("a fast golang crawler built on goroutines")
+			off := classifier.Relevance("garden tomatoes and fresh basil pasta")
+
+			if on <= off {
+				t.Fatalf("Relevance(on topic) = %.2f, want more than %.2f", on, off)
+			}
+			if on < 0 || on > 1 || off < 0 || off > 1 {
+				t.Fatalf("Relevance = %.2f, %.2f, want values between 0 and 1", on, off)
+			}
+		})
+	}
+}
+
+func TestFocused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `<html><body>
+			<p>writing a web crawler in golang using goroutines</p>
+			<a href="/golang-crawler">golang crawler</a>
+			<a href="/tomato-recipes">tomatoes</a>
+		</body></html>`)
+	}))
+	defer srv.Close()
+
+	crawler := Crawler{Client: srv.Client(), AllowedDepth: 1, Delay: TestDelay}
+	crawler.Init()
+	Focused(&crawler, &KeywordClassifier{Keywords: map[string]float64{"golang": 2, "crawler": 1, "goroutines": 1}}, 0.6)
+
+	var mu sync.Mutex
+	pruned := map[string]error{}
+	pushed := map[string]float64{}
+	crawler.OnError(func(r *Request, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		pruned[r.URL.Path] = err
+	})
+	crawler.OnPush(func(r *Request) error {
+		mu.Lock()
+		defer mu.Unlock()
+		pushed[r.URL.Path] = r.Priority
+		return nil
+	})
+
+	crawler.Run(srv.URL + "/")
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Page is fully relevant, anchor of tomato link isn't at all.
+	if !errors.Is(pruned["/tomato-recipes"], ErrIrrelevant) {
+		t.Fatalf("err = %v, want %v", pruned["/tomato-recipes"], ErrIrrelevant)
+	}
+	if _, exists := pushed["/tomato-recipes"]; exists {
+		t.Fatalf("pushed = %v, want /tomato-recipes pruned", pushed)
+	}
+	if score, exists := pushed["/golang-crawler"]; !exists || score < 0.6 {
+		t.Fatalf("pushed = %v, want /golang-crawler with score above threshold", pushed)
+	}
+}
@@ -1,6 +1,7 @@
 package hopper
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/url"
@@ -12,32 +13,158 @@ const (
 )
 
 type Client struct {
-	Client  *http.Client
-	Headers http.Header
+	Client     *http.Client
+	Headers    http.Header
+	Validators ValidatorStore
+	Cache      Cache
+	ForceCache bool
+	Jar        *CookieJar
+	// Authenticators are keyed by domain they are used for.
+	Authenticators map[string]Authenticator
+	Proxies        *ProxyPool
+	Profiles       *HeaderProfiles
+	Redirects      *RedirectPolicy
+	// Timeouts and MaxConnsPerHost configure transport of client, when
+	// Client is not set.
+	Timeouts        Timeouts
+	MaxConnsPerHost int
+	// HTTP3 is used for hosts which advertise HTTP/3, if it is set.
+	// Hopper does not implement HTTP/3 itself, this is slot for round
+	// tripper of QUIC library, e.g. http3.Transport of quic-go.
+	HTTP3 http.RoundTripper
 }
 
 func (c *Client) Init() {
 	if c.Client == nil {
-		c.Client = http.DefaultClient
-		c.Client.Timeout = DefaultClientTimeout
+		c.Client = &http.Client{Transport: NewTransport(c.Timeouts, c.MaxConnsPerHost), Timeout: c.Timeouts.total()}
+	}
+	if transport, ok := c.transport(); ok {
+		client := *c.Client
+		client.Transport = &ProxyTransport{Transport: transport.Clone(), Pool: c.Proxies}
+		c.Client = &client
+	}
+	// Pooled proxies can't relay HTTP/3.
+	if c.HTTP3 != nil && c.Proxies == nil {
+		client := *c.Client
+		client.Transport = &altSvcTransport{Transport: client.Transport, HTTP3: c.HTTP3}
+		c.Client = &client
+	}
+	if c.Redirects != nil {
+		client := *c.Client
+		client.CheckRedirect = c.Redirects.CheckRedirect
+		c.Client = &client
+	}
+	if c.Cache != nil {
+		client := *c.Client
+		client.Transport = &CacheTransport{Transport: client.Transport, Cache: c.Cache, ForceCache: c.ForceCache}
+		c.Client = &client
+	}
+	if c.Jar != nil {
+		client := *c.Client
+		client.Jar = c.Jar.Session("")
+		c.Client = &client
 	}
 
 	c.Headers = http.Header{}
 }
 
 func (c *Client) Do(method string, uri *url.URL, body io.Reader, headers http.Header) (*http.Response, error) {
-	req, err := http.NewRequest(method, uri.String(), body)
+	return c.do(context.Background(), c.Client, method, uri, body, headers)
+}
+
+// DoRequest sends request with its body within its cookie session, through its proxy
+// if one is set. Redirects of request are checked by Redirects policy.
+func (c *Client) DoRequest(req *Request) (*http.Response, error) {
+	return c.doRequest(context.Background(), req)
+}
+
+func (c *Client) doRequest(ctx context.Context, req *Request) (*http.Response, error) {
+	client := c.Client
+	if c.Jar != nil && req.Session != "" {
+		session := *c.Client
+		session.Jar = c.Jar.Session(req.Session)
+		client = &session
+	}
+
+	var body io.Reader
+	if req.Body != nil {
+		body = req.Body.Reader()
+		if req.Headers.Get("Content-Type") == "" && req.Body.ContentType != "" {
+			req.Headers.Set("Content-Type", req.Body.ContentType)
+		}
+	}
+
+	ctx = withRequest(withProxy(ctx, req.Proxy), req)
+
+	// Streamed bodies are limited by time between reads, not total time.
+	idle, ok := req.Properties["IdleTimeout"].(time.Duration)
+	if !ok || idle <= 0 {
+		return c.do(ctx, client, req.Method, req.URL, body, req.Headers)
+	}
+	unlimited := *client
+	unlimited.Timeout = 0
+
+	ctx, cancel := context.WithCancel(ctx)
+	res, err := c.do(ctx, &unlimited, req.Method, req.URL, body, req.Headers)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = newIdleBody(res.Body, idle, cancel)
+	return res, nil
+}
+
+// transport returns transport of client that proxies can be set on.
+func (c *Client) transport() (*http.Transport, bool) {
+	if c.Client.Transport == nil {
+		return http.DefaultTransport.(*http.Transport), true
+	}
+	transport, ok := c.Client.Transport.(*http.Transport)
+	return transport, ok
+}
+
+func (c *Client) do(ctx context.Context, client *http.Client, method string, uri *url.URL, body io.Reader, headers http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(withTiming(ctx), method, uri.String(), body)
 	if err != nil {
 		return nil, err
 	}
 
+	if c.Profiles != nil {
+		if profile, ok := c.Profiles.Profile(uri.Hostname()); ok {
+			for k, v := range profile.Header() {
+				if headers.Get(k) == "" {
+					headers[k] = v
+				}
+			}
+		}
+	}
+
     for k, v := range c.Headers {
 		if headers.Get(k) == "" {
 			headers[k] = v
 		}
 	}
 
+	// Bodies are decoded by client, for every encoding it supports.
+	if headers.Get("Accept-Encoding") == "" {
+		headers.Set("Accept-Encoding", DefaultAcceptEncoding)
+	}
+
 	req.Header = headers
+	if c.Validators != nil {
+		setConditional(c.Validators, req)
+	}
+
+	res, err := c.sendAuthenticated(client, req)
+	if err != nil {
+		return nil, err
+	}
+	if c.Validators != nil {
+		storeValidators(c.Validators, uri, res)
+	}
+	if err := decodeBody(res); err != nil {
+		return nil, err
+	}
 
-	return c.Client.Do(req)
+	return res, nil
 }
@@ -0,0 +1,165 @@
+package hopper
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieJar keeps cookies of crawl isolated in named sessions. Requests
+// use session given by Request.Session, or default "" session. With
+// PerHost each host of session gets separate jar, so that cookies are
+// never shared even between subdomains.
+type CookieJar struct {
+	sync.Mutex
+
+	PerHost bool
+
+	sessions map[string]*sessionJar
+}
+
+type cookieRecord struct {
+	URL    string
+	Cookie *http.Cookie
+}
+
+// sessionJar is http.CookieJar of single session. It remembers set
+// cookies, because cookiejar.Jar can't list them for persistence.
+type sessionJar struct {
+	sync.Mutex
+
+	perHost bool
+	jars    map[string]*cookiejar.Jar
+	records map[string]cookieRecord
+}
+
+// Session returns cookie jar of named session, creating it if necessary.
+func (cj *CookieJar) Session(name string) http.CookieJar {
+	cj.Lock()
+	defer cj.Unlock()
+
+	return cj.session(name)
+}
+
+func (cj *CookieJar) session(name string) *sessionJar {
+	if cj.sessions == nil {
+		cj.sessions = map[string]*sessionJar{}
+	}
+
+	session, exists := cj.sessions[name]
+	if !exists {
+		session = &sessionJar{perHost: cj.PerHost, jars: map[string]*cookiejar.Jar{}, records: map[string]cookieRecord{}}
+		cj.sessions[name] = session
+	}
+
+	return session
+}
+
+// Save writes all unexpired cookies of every session to file at path.
+func (cj *CookieJar) Save(path string) error {
+	cj.Lock()
+	defer cj.Unlock()
+
+	now := time.Now()
+	saved := map[string][]cookieRecord{}
+	for name, session := range cj.sessions {
+		session.Lock()
+		for _, record := range session.records {
+			if record.Cookie.MaxAge < 0 || (!record.Cookie.Expires.IsZero() && record.Cookie.Expires.Before(now)) {
+				continue
+			}
+			saved[name] = append(saved[name], record)
+		}
+		session.Unlock()
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Load restores cookies saved by Save. Missing file is not an error,
+// so that first run of crawl can use the same path.
+func (cj *CookieJar) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	saved := map[string][]cookieRecord{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+
+	cj.Lock()
+	defer cj.Unlock()
+
+	for name, records := range saved {
+		session := cj.session(name)
+		for _, record := range records {
+			uri, err := url.Parse(record.URL)
+			if err != nil {
+				continue
+			}
+			session.SetCookies(uri, []*http.Cookie{record.Cookie})
+		}
+	}
+
+	return nil
+}
+
+func (sj *sessionJar) jar(uri *url.URL) *cookiejar.Jar {
+	key := ""
+	if sj.perHost {
+		key = uri.Hostname()
+	}
+
+	jar, exists := sj.jars[key]
+	if !exists {
+		// Error is returned only for invalid options.
+		jar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		sj.jars[key] = jar
+	}
+
+	return jar
+}
+
+func (sj *sessionJar) Cookies(uri *url.URL) []*http.Cookie {
+	sj.Lock()
+	defer sj.Unlock()
+
+	return sj.jar(uri).Cookies(uri)
+}
+
+func (sj *sessionJar) SetCookies(uri *url.URL, cookies []*http.Cookie) {
+	sj.Lock()
+	defer sj.Unlock()
+
+	sj.jar(uri).SetCookies(uri, cookies)
+
+	origin := &url.URL{Scheme: uri.Scheme, Host: uri.Host, Path: uri.Path}
+	for _, cookie := range cookies {
+		key := cookie.Domain + "|" + cookie.Path + "|" + cookie.Name + "|" + uri.Host
+		if cookie.MaxAge > 0 && cookie.Expires.IsZero() {
+			expires := *cookie
+			expires.Expires = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+			expires.MaxAge = 0
+			cookie = &expires
+		}
+		sj.records[key] = cookieRecord{URL: origin.String(), Cookie: cookie}
+	}
+}
@@ -0,0 +1,55 @@
+package hopper
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestCookieJar(t *testing.T) {
+	uri, _ := url.Parse("https://www.example.com/")
+	sub, _ := url.Parse("https://shop.example.com/")
+	cookie := &http.Cookie{Name: "consent", Value: "yes", Domain: "example.com", MaxAge: 3600}
+
+	t.Run("Sessions", func(t *testing.T) {
+		jar := &CookieJar{}
+		jar.Session("a").SetCookies(uri, []*http.Cookie{cookie})
+
+		if n := len(jar.Session("a").Cookies(sub)); n != 1 {
+			t.Fatalf("len(Cookies) = %d, want %d", n, 1)
+		}
+		if n := len(jar.Session("b").Cookies(uri)); n != 0 {
+			t.Fatalf("len(Cookies) = %d, want %d", n, 0)
+		}
+	})
+
+	t.Run("PerHost", func(t *testing.T) {
+		jar := &CookieJar{PerHost: true}
+		jar.Session("").SetCookies(uri, []*http.Cookie{cookie})
+
+		if n := len(jar.Session("").Cookies(sub)); n != 0 {
+			t.Fatalf("len(Cookies) = %d, want %d", n, 0)
+		}
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cookies.json")
+
+		jar := &CookieJar{}
+		jar.Session("a").SetCookies(uri, []*http.Cookie{cookie, {Name: "expired", Value: "x", MaxAge: -1}})
+		if err := jar.Save(path); err != nil {
+			t.Fatal(err)
+		}
+
+		restored := &CookieJar{}
+		if err := restored.Load(path); err != nil {
+			t.Fatal(err)
+		}
+
+		cookies := restored.Session("a").Cookies(sub)
+		if len(cookies) != 1 || cookies[0].Value != "yes" {
+			t.Fatalf("Cookies = %v, want [consent=yes]", cookies)
+		}
+	})
+}
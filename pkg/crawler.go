@@ -1,6 +1,8 @@
 package hopper
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -18,17 +20,40 @@ type Crawler struct {
 	Concurrency       int
 	Delay             time.Duration
 	UserAgent         string
+	RobotToken        string
+	Profiles          *HeaderProfiles
+	Redirects         *RedirectPolicy
 	AllowedDomains    []string
 	DisallowedDomains []string
 	AllowedDepth      int
+	Filters           []Filter
+	Scope             Scope
+	OffsiteHops       int
+	Scorer            Scorer
+	Validators        ValidatorStore
+	Cache             Cache
+	ForceCache        bool
+	Jar               *CookieJar
+	Authenticators    map[string]Authenticator
+	Proxies           *ProxyPool
 	ContentLength     int64
+	BodyTimeout       time.Duration
+	ScanScripts       bool
+	SubmitForms       bool
+	Timeouts          Timeouts
+	HostConcurrency   int
+	HTTP3             http.RoundTripper
 	Client            *http.Client
+	Fetchers          map[string]Fetcher
+	Normalizer        *Normalizer
+	Seen              SeenSet
 
 	queue   *URLQueue
     client  *Client
 	request *Request
 
 	onRequest  []RequestHandler
+	onRedirect []RequestHandler
 	onPush     []PushHandler
 	onResponse []ResponseHandler
 	onError    []ErrorHandler
@@ -36,48 +61,105 @@ type Crawler struct {
 
 // Init initializes default values for crawler.
 func (c *Crawler) Init() {
-	c.queue = &URLQueue{Max: c.Concurrency}
+	c.queue = &URLQueue{Max: c.Concurrency, Seen: c.Seen}
 	c.queue.Init()
 
-	c.client = &Client{Client: c.Client}
+	if c.Validators == nil {
+		c.Validators = &MemoryValidatorStore{}
+	}
+
+	if c.Jar == nil {
+		c.Jar = &CookieJar{}
+	}
+
+	redirects := &RedirectPolicy{}
+	if c.Redirects != nil {
+		policy := *c.Redirects
+		redirects = &policy
+	}
+	redirects.check = c.checkRedirect
+
+	c.client = &Client{Client: c.Client, Validators: c.Validators, Cache: c.Cache, ForceCache: c.ForceCache, Jar: c.Jar, Authenticators: c.Authenticators, Proxies: c.Proxies, Profiles: c.Profiles, Redirects: redirects, Timeouts: c.Timeouts, MaxConnsPerHost: c.hostConcurrency(), HTTP3: c.HTTP3}
 	c.client.Init()
 	c.client.Headers.Set("User-Agent", c.UserAgent)
 
 	c.request = &Request{}
 	c.request.Init()
-	c.request.Properties["AllowedDomains"] = c.AllowedDomains
-	c.request.Properties["DisallowedDomains"] = c.DisallowedDomains
+	c.request.Properties["Filters"] = c.filters()
+	c.request.Properties["Scope"] = c.Scope
+	c.request.Properties["OffsiteHops"] = c.OffsiteHops
 	c.request.Properties["AllowedDepth"] = c.AllowedDepth
+	c.request.Properties["Normalizer"] = c.Normalizer
+	c.request.Properties["ScanScripts"] = c.ScanScripts
+	c.request.Properties["SubmitForms"] = c.SubmitForms
 
 	if c.UserAgent == "" {
 		c.client.Headers.Set("User-Agent", DefaultUserAgent)
 	}
-	if c.AllowedDomains == nil {
-		c.request.Properties["AllowedDomains"] = []string{}
-	}
-	if c.DisallowedDomains == nil {
-		c.request.Properties["DisallowedDomains"] = []string{}
+	if c.RobotToken == "" {
+		c.RobotToken = c.client.Headers.Get("User-Agent")
 	}
+	c.request.Properties["UserAgent"] = c.client.Headers.Get("User-Agent")
 	if c.AllowedDepth == 0 {
 		c.request.Properties["AllowedDepth"] = math.MaxInt
 	}
+	if c.Normalizer == nil {
+		c.request.Properties["Normalizer"] = DefaultNormalizer()
+	}
 	if c.ContentLength == 0 {
 		c.request.Properties["ContentLength"] = int64(4000000)
+	} else {
+		c.request.Properties["ContentLength"] = c.ContentLength
+	}
+	if c.BodyTimeout != 0 {
+		c.request.Properties["BodyTimeout"] = c.BodyTimeout
 	}
     if int(c.Delay) == 0 {
         c.Delay = DefaultDelay
     }
+	if c.Scorer == nil {
+		c.Scorer = BFSScorer
+	}
 
 	c.onRequest = []RequestHandler{}
+	c.onRedirect = []RequestHandler{}
 	c.onResponse = []ResponseHandler{}
 	c.onPush = []PushHandler{}
 	c.onError = []ErrorHandler{}
 }
 
+// hostConcurrency returns limit of connections to single host, which
+// defaults to number of workers.
+func (c *Crawler) hostConcurrency() int {
+	if c.HostConcurrency == 0 {
+		return c.queue.Max
+	}
+	return c.HostConcurrency
+}
+
+// filters returns filter chain built from domain lists and Filters.
+func (c *Crawler) filters() []Filter {
+	filters := []Filter{}
+	if len(c.AllowedDomains) > 0 {
+		filters = append(filters, AllowDomains(c.AllowedDomains...))
+	}
+	if len(c.DisallowedDomains) > 0 {
+		filters = append(filters, DisallowDomains(c.DisallowedDomains...))
+	}
+
+	return append(filters, c.Filters...)
+}
+
 func (c *Crawler) OnRequest(fn RequestHandler) {
 	c.onRequest = append(c.onRequest, fn)
 }
 
+// OnRedirect registers handler which checks each target of followed
+// redirect. Request handlers only run for requests which were popped.
+func (c *Crawler) OnRedirect(fn RequestHandler) {
+	c.onRedirect = append(c.onRedirect, fn)
+}
+
 func (c *Crawler) OnResponse(fn ResponseHandler) {
 	c.onResponse = append(c.onResponse, fn)
 }
@@ -90,18 +172,53 @@ func (c *Crawler) OnError(fn ErrorHandler) {
 	c.onError = append(c.onError, fn)
 }
 
+// report passes error of request to error handlers.
+func (c *Crawler) report(req *Request, err error) {
+	for _, fn := range c.onError {
+		fn(req, err)
+	}
+}
+
 // Run is responsible for creating crawler workers.
 func (c *Crawler) Run(seeds ...string) {
 	if len(seeds) == 0 {
 		panic("Cannot run crawler without seeds")
 	}
 
+	requests := []*Request{}
 	for _, seed := range seeds {
 		req, err := c.request.New("GET", seed)
 		if err != nil {
 			continue
 		}
-		go c.Push(req)
+		requests = append(requests, req)
+	}
+
+	c.run(requests)
+}
+
+// Root returns request which seed requests are created from, so that
+// they share configuration of crawler.
+func (c *Crawler) Root() Request {
+	return *c.request
+}
+
+// RunRequests is like Run, but starts from requests created from Root.
+func (c *Crawler) RunRequests(seeds ...*Request) {
+	if len(seeds) == 0 {
+		panic("Cannot run crawler without seeds")
+	}
+
+	c.run(seeds)
+}
+
+func (c *Crawler) run(seeds []*Request) {
+	for _, req := range seeds {
+		c.queue.Begin()
+		go func(req *Request) {
+			defer c.queue.Done()
+			c.Push(req)
+		}(req)
 	}
 
 	for free := range c.queue.Free {
@@ -109,25 +226,25 @@ func (c *Crawler) Run(seeds ...string) {
 			go c.Traverse()
 		}
 	}
-
 }
 
 // Traverse starts crawl proccess until all links have been crawled.
 func (c *Crawler) Traverse() {
-	for c.queue.Len() > 0 {
+	for {
 		req := c.queue.Pop()
+		if req == nil {
+			if c.queue.Leave() {
+				return
+			}
+			continue
+		}
 
 		err := c.Visit(req)
 		if err != nil {
-			for _, fn := range c.onError {
-				fn(req, err)
-			}
-		}    
+			c.report(req, err)
+		}
+		c.queue.Done()
 	}
-
-    if c.queue.Threads() == 0 {
-        c.queue.Close()
-    }
 }
 
 func (c *Crawler) Visit(req *Request) error {
@@ -139,14 +256,32 @@ func (c *Crawler) Visit(req *Request) error {
 		}
 	}
 
-	httpRes, err := c.client.Do(req.Method, req.URL, nil, req.Headers)
+	fetcher, err := c.fetcher(req)
 	if err != nil {
 		return fmt.Errorf("Request: %w", err)
 	}
 
-	res, err := NewResponse(httpRes, req.Properties, req)
+	res, err := fetcher.Fetch(context.Background(), req)
+
+	// Redirect which was not followed is crawled as new request.
+	var redirect *RedirectError
+	if errors.As(err, &redirect) {
+		target, err := req.New(http.MethodGet, redirect.Location.String())
+		if err != nil {
+			return fmt.Errorf("Redirect: %w", err)
+		}
+		return c.Push(target)
+	}
 	if err != nil {
-		return fmt.Errorf("Response: %w", err)
+		return fmt.Errorf("Request: %w", err)
+	}
+	defer res.Close()
+
+	// Final url of followed redirect is not crawled again.
+	if len(res.Redirects) > 0 {
+		if final, err := redirectRequest(req, http.MethodGet, res.URL.String()); err == nil {
+			c.queue.See(final)
+		}
 	}
 
 	for _, fn := range c.onResponse {
@@ -157,24 +292,51 @@ func (c *Crawler) Visit(req *Request) error {
 		}
 	}
 
+	// Links are pushed even when some were rejected.
 	discovered, err := res.Do()
-	if err != nil {
-		return fmt.Errorf("Response: %w", err)
-	}
 
 	for _, discovery := range discovered {
         err := c.Push(discovery)
         if err != nil {
-            for _, fn := range c.onError {
-                fn(discovery, err)
-            }
+            c.report(discovery, err)
         }
 	}
 
+	if err != nil {
+		return fmt.Errorf("Response: %w", err)
+	}
 	return nil
 }
 
+// checkRedirect validates redirect target of request and runs redirect
+// handlers on it.
+func (c *Crawler) checkRedirect(req *Request, target *http.Request) error {
+	hop, err := redirectRequest(req, target.Method, target.URL.String())
+	if err != nil {
+		return fmt.Errorf("Redirect: %w", err)
+	}
+
+	for _, fn := range c.onRedirect {
+		if err := fn(hop); err != nil {
+			return fmt.Errorf("Redirect: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// redirectRequest creates request of redirect target of req.
+func redirectRequest(req *Request, method string, uri string) (*Request, error) {
+	// Redirect does not make path deeper.
+	parent := *req
+	parent.Depth--
+
+	return parent.New(method, uri)
+}
+
 func (c *Crawler) Push(req *Request) error {
+	req.Priority = c.Scorer(req)
+
     for _, fn := range c.onPush {
         err := fn(req)
         if err != nil {
@@ -0,0 +1,54 @@
+package hopper
+
+import (
+	"mime"
+	"regexp"
+	"strings"
+)
+
+// cssURL matches url() references and @import strings of stylesheet.
+// Each alternative captures url without its quotes.
+var cssURL = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)"'\s]*))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// rewriteCSS replaces each url referenced by css with url returned by fn.
+// Quoting of references is kept.
+func rewriteCSS(css string, fn func(uri string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, match := range cssURL.FindAllStringSubmatchIndex(css, -1) {
+		for group := 2; group < len(match); group += 2 {
+			start, end := match[group], match[group+1]
+			if start < 0 {
+				continue
+			}
+			b.WriteString(css[last:start])
+			b.WriteString(fn(css[start:end]))
+			last = end
+			break
+		}
+	}
+	b.WriteString(css[last:])
+
+	return b.String()
+}
+
+// cssLinks returns urls referenced by css.
+func cssLinks(css string) []string {
+	links := []string{}
+	rewriteCSS(css, func(uri string) string {
+		if uri = strings.TrimSpace(uri); uri != "" && !strings.HasPrefix(uri, "data:") {
+			links = append(links, uri)
+		}
+		return uri
+	})
+	return links
+}
+
+// IsCSS reports whether response is stylesheet.
+func (res *Response) IsCSS() bool {
+	mediaType, _, err := mime.ParseMediaType(res.Headers.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/css"
+}
@@ -0,0 +1,29 @@
+package hopper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssetLinks(t *testing.T) {
+	tests := []struct {
+		Name        string
+		ContentType string
+		Body        string
+		Want        []string
+	}{
+		{"Stylesheet", "text/css", `@import "base.css"; @import url(print.css); a { background: url( '/img/a.png' ) } b { background: url(data:image/png;base64,AA==) }`, []string{"/css/base.css", "/css/print.css", "/img/a.png"}},
+		{"InlineStyles", "text/html", `<link rel="stylesheet" href="site.css"><style>body { background: url("/bg.png") }</style><div style="background: url(/div.png)"></div>`, []string{"/css/site.css", "/bg.png", "/div.png"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, discovered := discover(t, "http://example.com/css/page", test.ContentType, test.Body, nil)
+
+			got := paths(discovered)
+			if strings.Join(got, " ") != strings.Join(test.Want, " ") {
+				t.Fatalf("Response.Do() = %v, want %v", got, test.Want)
+			}
+		})
+	}
+}
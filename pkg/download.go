@@ -0,0 +1,436 @@
+package hopper
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDownloadRetries     = 3
+	DefaultDownloadIdleTimeout = 30 * time.Second
+)
+
+var (
+	ErrChecksumMismatch = errors.New("Checksum mismatch")
+	ErrDownloadTooLarge = errors.New("Download too large")
+	ErrBodyBuffered     = errors.New("Body was already buffered")
+	ErrRangeMismatch    = errors.New("Range does not continue download")
+)
+
+// DefaultDownloadExtensions are downloaded instead of being parsed.
+var DefaultDownloadExtensions = []string{
+	".pdf", ".zip", ".gz", ".tgz", ".tar", ".bz2", ".xz", ".7z",
+	".csv", ".tsv", ".parquet", ".xls", ".xlsx", ".doc", ".docx",
+}
+
+// Download is file streamed to disk, named by its content. Ref is its
+// reference in storage, when it was moved there.
+type Download struct {
+	Path   string
+	Ref    string
+	SHA256 string
+	Size   int64
+}
+
+// DownloadsMiddleware streams matching responses to Dir instead of
+// buffering them. Interrupted downloads are resumed with range requests.
+// Partial files are kept under Dir, so that downloads matched by their
+// extension are also resumed by later crawls. Files are named by sha256
+// of their content, so that equal files are stored once.
+//
+// Downloads matched by their extension and resumed ones are not limited
+// by total timeout of client, but by IdleTimeout between reads of body.
+type DownloadsMiddleware struct {
+	Dir        string
+	Extensions []string
+	// MediaTypes of responses downloaded regardless of extension.
+	MediaTypes  []string
+	MaxRetries  int
+	IdleTimeout time.Duration
+	// MaxSize limits size of single download, unlimited if 0.
+	MaxSize    int64
+	OnProgress func(req *Request, written int64, total int64)
+	// Storage receives completed downloads instead of Dir, if set.
+	Storage Storage
+
+	client *Client
+}
+
+// Downloads registers download mode on crawler. Expected sha256 checksum
+// is taken from Checksum property of request, or from Content-Digest and
+// Digest headers of response.
+func Downloads(crawler *Crawler, dir string) *DownloadsMiddleware {
+	dm := &DownloadsMiddleware{
+		Dir:         dir,
+		Extensions:  DefaultDownloadExtensions,
+		MediaTypes:  []string{"application/pdf", "application/zip", "application/octet-stream"},
+		MaxRetries:  DefaultDownloadRetries,
+		IdleTimeout: DefaultDownloadIdleTimeout,
+		client:      crawler.client,
+	}
+
+	// Encoded bodies can't be resumed at byte offset.
+	crawler.OnRequest(func(r *Request) error {
+		if dm.matchURL(r) {
+			r.Headers.Set("Accept-Encoding", "identity")
+			r.Properties["IdleTimeout"] = dm.IdleTimeout
+			dm.resume(r)
+		}
+		return nil
+	})
+
+	crawler.OnResponse(func(r *Response) error {
+		if r.NotModified || !dm.Match(r) {
+			return nil
+		}
+
+		download, err := dm.Download(r)
+		if err != nil {
+			return fmt.Errorf("Download: %w", err)
+		}
+
+		r.Download = download
+		r.NoFollow = true
+		r.body = []byte{}
+		return nil
+	})
+
+	return dm
+}
+
+// Match reports whether response is downloaded.
+func (dm *DownloadsMiddleware) Match(res *Response) bool {
+	if dm.matchURL(res.Request) {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Headers.Get("Content-Type"))
+	for _, t := range dm.MediaTypes {
+		if strings.EqualFold(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (dm *DownloadsMiddleware) matchURL(req *Request) bool {
+	ext := strings.ToLower(path.Ext(req.URL.Path))
+	for _, e := range dm.Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Download streams body of response to partial file and moves it under
+// its content address once it is complete and verified. Body must not
+// have been read by Bytes before, so handlers which read it have to be
+// registered after Downloads.
+func (dm *DownloadsMiddleware) Download(res *Response) (*Download, error) {
+	if res.body != nil {
+		return nil, ErrBodyBuffered
+	}
+
+	partials := filepath.Join(dm.Dir, ".partial")
+	if err := os.MkdirAll(partials, 0o755); err != nil {
+		return nil, err
+	}
+
+	partial := dm.partialPath(res.Request)
+	if res.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// Partial file is unusable, next request starts over.
+		removePartial(partial)
+		return nil, fmt.Errorf("Resume responded with %d", res.StatusCode)
+	}
+	file, err := os.OpenFile(partial, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Partial file is continued only by range it was requested with, it
+	// starts over only with whole file.
+	var written int64
+	total := res.contentLength()
+	start, length := contentRange(res.Headers)
+	switch {
+	case res.StatusCode == http.StatusPartialContent && start == info.Size():
+		written = info.Size()
+		total = length
+	case res.StatusCode == http.StatusPartialContent:
+		file.Close()
+		removePartial(partial)
+		return nil, fmt.Errorf("Range starts at %d, want %d: %w", start, info.Size(), ErrRangeMismatch)
+	default:
+		if err := file.Truncate(0); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if res.StatusCode == http.StatusOK {
+		dm.saveValidator(partial, res.Headers)
+	}
+
+	written, err = dm.stream(res, file, written, total)
+	file.Close()
+	if err != nil {
+		// Interrupted download is resumed by next request of it.
+		if errors.Is(err, ErrDownloadTooLarge) {
+			removePartial(partial)
+		}
+		return nil, err
+	}
+
+	sum, err := fileSHA256(partial)
+	if err != nil {
+		removePartial(partial)
+		return nil, err
+	}
+	if expected := expectedChecksum(res); expected != "" && !strings.EqualFold(expected, sum) {
+		removePartial(partial)
+		return nil, fmt.Errorf("Got %s, want %s: %w", sum, expected, ErrChecksumMismatch)
+	}
+
+	if dm.Storage != nil {
+		ref, err := dm.store(partial, sum+downloadExtension(res))
+		removePartial(partial)
+		if err != nil {
+			return nil, err
+		}
+		return &Download{Ref: ref, SHA256: sum, Size: written}, nil
+	}
+
+	name := filepath.Join(dm.Dir, sum+downloadExtension(res))
+	if err := os.Rename(partial, name); err != nil {
+		removePartial(partial)
+		return nil, err
+	}
+	removePartial(partial)
+
+	return &Download{Path: name, SHA256: sum, Size: written}, nil
+}
+
+// partialPath returns path of partial file of request.
+func (dm *DownloadsMiddleware) partialPath(req *Request) string {
+	key := sha256.Sum256([]byte(req.Key()))
+	return filepath.Join(dm.Dir, ".partial", hex.EncodeToString(key[:16]))
+}
+
+// resume requests rest of partial file left by earlier crawl, if server
+// can confirm it has not changed since.
+func (dm *DownloadsMiddleware) resume(req *Request) {
+	partial := dm.partialPath(req)
+	info, err := os.Stat(partial)
+	if err != nil || info.Size() == 0 {
+		return
+	}
+	validator, err := os.ReadFile(partial + ".validator")
+	if err != nil || len(validator) == 0 {
+		return
+	}
+
+	req.Headers.Set("Range", fmt.Sprintf("bytes=%d-", info.Size()))
+	req.Headers.Set("If-Range", string(validator))
+}
+
+// saveValidator stores validator of downloaded file next to its partial
+// file, so that later crawls can resume it.
+func (dm *DownloadsMiddleware) saveValidator(partial string, headers http.Header) {
+	validator := headers.Get("ETag")
+	if validator == "" {
+		validator = headers.Get("Last-Modified")
+	}
+	if validator == "" {
+		os.Remove(partial + ".validator")
+		return
+	}
+	os.WriteFile(partial+".validator", []byte(validator), 0o644)
+}
+
+func removePartial(partial string) {
+	os.Remove(partial)
+	os.Remove(partial + ".validator")
+}
+
+func (dm *DownloadsMiddleware) store(partial string, key string) (string, error) {
+	file, err := os.Open(partial)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return dm.Storage.Put(key, file)
+}
+
+// stream appends body to file, which already holds written bytes of
+// total, and resumes it with range requests when reading fails.
+func (dm *DownloadsMiddleware) stream(res *Response, file *os.File, written int64, total int64) (int64, error) {
+	body := res.raw
+	// Decoded bodies can only be restarted, not resumed.
+	_, decoded := body.(*decodedBody)
+
+	for attempt := 0; ; attempt++ {
+		n, err := dm.copy(res.Request, file, body, written, total)
+		written += n
+		drainBody(body)
+		if err == nil || errors.Is(err, ErrDownloadTooLarge) || attempt >= dm.MaxRetries {
+			return written, err
+		}
+
+		resume := *res.Request
+		resume.Properties = map[string]any{}
+		for k, v := range res.Request.Properties {
+			resume.Properties[k] = v
+		}
+		resume.Properties["IdleTimeout"] = dm.IdleTimeout
+		resume.Headers = res.Request.Headers.Clone()
+		resume.Headers.Set("Accept-Encoding", "identity")
+		resume.Headers.Del("Range")
+		resume.Headers.Del("If-Range")
+		if !decoded && written > 0 {
+			resume.Headers.Set("Range", fmt.Sprintf("bytes=%d-", written))
+			if validator := res.Headers.Get("ETag"); validator != "" {
+				resume.Headers.Set("If-Range", validator)
+			} else if validator := res.Headers.Get("Last-Modified"); validator != "" {
+				resume.Headers.Set("If-Range", validator)
+			}
+		}
+
+		httpRes, err := dm.client.DoRequest(&resume)
+		if err != nil {
+			continue
+		}
+
+		start, _ := contentRange(httpRes.Header)
+		switch {
+		case httpRes.StatusCode == http.StatusPartialContent && start == written:
+		case httpRes.StatusCode == http.StatusOK:
+			// Server ignored range, download starts over.
+			if err := file.Truncate(0); err != nil {
+				drainBody(httpRes.Body)
+				return written, err
+			}
+			written = 0
+			total = httpRes.ContentLength
+		default:
+			drainBody(httpRes.Body)
+			return written, fmt.Errorf("Resume responded with %d", httpRes.StatusCode)
+		}
+		body = httpRes.Body
+	}
+}
+
+func (dm *DownloadsMiddleware) copy(req *Request, dst io.Writer, src io.Reader, offset int64, total int64) (int64, error) {
+	buf := make([]byte, 32<<10)
+	var n int64
+	for {
+		read, err := src.Read(buf)
+		if read > 0 {
+			if dm.MaxSize > 0 && offset+n+int64(read) > dm.MaxSize {
+				return n, fmt.Errorf("Size exceeds %d bytes: %w", dm.MaxSize, ErrDownloadTooLarge)
+			}
+			if _, err := dst.Write(buf[:read]); err != nil {
+				return n, err
+			}
+			n += int64(read)
+			if dm.OnProgress != nil {
+				dm.OnProgress(req, offset+n, total)
+			}
+		}
+		if err == io.EOF {
+			if total > 0 && offset+n < total {
+				return n, io.ErrUnexpectedEOF
+			}
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// contentLength returns length of response body, -1 if unknown.
+func (res *Response) contentLength() int64 {
+	length, err := strconv.ParseInt(res.Headers.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return length
+}
+
+// contentRange returns start of range and length of whole file, -1 if
+// they are unknown.
+func contentRange(header http.Header) (int64, int64) {
+	var start, end, total int64
+	if _, err := fmt.Sscanf(header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		return -1, -1
+	}
+	return start, total
+}
+
+// expectedChecksum returns hex encoded sha256 which download should have.
+func expectedChecksum(res *Response) string {
+	if checksum, ok := res.Request.Properties["Checksum"].(string); ok {
+		return strings.TrimPrefix(checksum, "sha256:")
+	}
+
+	for _, header := range []string{"Content-Digest", "Digest"} {
+		for _, digest := range strings.Split(res.Headers.Get(header), ",") {
+			algorithm, value, found := strings.Cut(strings.TrimSpace(digest), "=")
+			if !found || !strings.EqualFold(algorithm, "sha-256") {
+				continue
+			}
+			sum, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+			if err == nil {
+				return hex.EncodeToString(sum)
+			}
+		}
+	}
+
+	return ""
+}
+
+// downloadExtension returns extension of url path, or one of media type.
+func downloadExtension(res *Response) string {
+	if ext := path.Ext(res.URL.Path); ext != "" && len(ext) <= 8 {
+		return strings.ToLower(ext)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Headers.Get("Content-Type"))
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+func fileSHA256(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
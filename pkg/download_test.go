@@ -0,0 +1,269 @@
+package hopper
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloads(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<13)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	var ranges atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges.Add(1)
+		}
+		if r.URL.Path == "/broken.pdf" && r.Header.Get("Range") == "" {
+			// Connection drops in the middle of body.
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Header().Set("ETag", `"v1"`)
+			w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.pdf", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		Name     string
+		Path     string
+		Checksum string
+		Buffered bool
+		Err      error
+		Ranges   int32
+	}{
+		{"Complete", "/file.pdf", "", false, nil, 0},
+		{"Resumed", "/broken.pdf", checksum, false, nil, 1},
+		{"ChecksumMismatch", "/file.pdf", "sha256:" + checksum[1:] + "0", false, ErrChecksumMismatch, 0},
+		{"Buffered", "/file.pdf", "", true, ErrBodyBuffered, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			ranges.Store(0)
+
+			crawler := Crawler{Client: srv.Client()}
+			crawler.Init()
+			dm := Downloads(&crawler, t.TempDir())
+
+			var progress int64
+			dm.OnProgress = func(req *Request, written int64, total int64) {
+				progress = written
+			}
+
+			root := crawler.Root()
+			req, _ := root.New(http.MethodGet, srv.URL+test.Path)
+			if test.Checksum != "" {
+				req.Properties["Checksum"] = test.Checksum
+			}
+			httpRes, err := crawler.client.DoRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := NewResponse(httpRes, req.Properties, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.Buffered {
+				res.Bytes()
+			}
+
+			download, err := dm.Download(res)
+			if !errors.Is(err, test.Err) {
+				t.Fatalf("err = %v, want %v", err, test.Err)
+			}
+			if ranges.Load() != test.Ranges {
+				t.Fatalf("range requests = %d, want %d", ranges.Load(), test.Ranges)
+			}
+			if err != nil {
+				return
+			}
+
+			stored, err := os.ReadFile(download.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if download.SHA256 != checksum || !bytes.Equal(stored, content) || progress != int64(len(content)) {
+				t.Fatalf("Download = %s %d bytes, progress %d, want %s %d bytes", download.SHA256, len(stored), progress, checksum, len(content))
+			}
+		})
+	}
+}
+
+func TestDownloadsResumeCrawl(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<13)
+
+	var cut atomic.Bool
+	cut.Store(true)
+	ranges := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges <- r.Header.Get("Range") + " " + r.Header.Get("If-Range")
+		}
+		if cut.Load() {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Header().Set("ETag", `"v1"`)
+			w.Write(content[:1000])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file.pdf", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	crawl := func() (*Download, error) {
+		crawler := Crawler{Client: srv.Client(), Delay: TestDelay}
+		crawler.Init()
+		dm := Downloads(&crawler, dir)
+		dm.MaxRetries = 0
+
+		var download *Download
+		var visitErr error
+		crawler.OnResponse(func(r *Response) error {
+			download = r.Download
+			return nil
+		})
+		crawler.OnError(func(r *Request, err error) {
+			visitErr = err
+		})
+		crawler.Run(srv.URL + "/file.pdf")
+		return download, visitErr
+	}
+
+	if _, err := crawl(); err == nil {
+		t.Fatalf("err = nil, want interrupted download")
+	}
+
+	cut.Store(false)
+	download, err := crawl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-ranges; got != `bytes=1000- "v1"` {
+		t.Fatalf("Range = %s, want rest of partial file", got)
+	}
+
+	stored, err := os.ReadFile(download.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, content) {
+		t.Fatalf("Download = %d bytes, want %d bytes", len(stored), len(content))
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, ".partial")); len(entries) != 0 {
+		t.Fatalf("partial files = %d, want 0", len(entries))
+	}
+}
+
+func TestDownloadsSlow(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gap, _ := time.ParseDuration(r.URL.Query().Get("gap"))
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		// Body is streamed for longer than total timeout of client.
+		for i := 0; i < 4; i++ {
+			w.Write(content[i*len(content)/4 : (i+1)*len(content)/4])
+			w.(http.Flusher).Flush()
+			time.Sleep(gap)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		Name string
+		Gap  time.Duration
+		Err  error
+	}{
+		{"Progressing", 150 * time.Millisecond, nil},
+		{"Stalled", 400 * time.Millisecond, context.Canceled},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			crawler := Crawler{Client: &http.Client{Timeout: 200 * time.Millisecond}, Delay: TestDelay}
+			crawler.Init()
+			dm := Downloads(&crawler, t.TempDir())
+			dm.MaxRetries = 0
+			dm.IdleTimeout = 300 * time.Millisecond
+
+			var download *Download
+			var visitErr error
+			crawler.OnResponse(func(r *Response) error {
+				download = r.Download
+				return nil
+			})
+			crawler.OnError(func(r *Request, err error) {
+				visitErr = err
+			})
+			crawler.Run(srv.URL + "/file.pdf?gap=" + test.Gap.String())
+
+			if !errors.Is(visitErr, test.Err) {
+				t.Fatalf("err = %v, want %v", visitErr, test.Err)
+			}
+			if test.Err == nil && (download == nil || download.Size != int64(len(content))) {
+				t.Fatalf("Download = %+v, want %d bytes", download, len(content))
+			}
+		})
+	}
+}
+
+func TestDownloadsRangeMismatch(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Range other than requested one is served.
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 500-%d/%d", len(content)-1, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[500:])
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	crawler := Crawler{Client: srv.Client(), Delay: TestDelay}
+	crawler.Init()
+	dm := Downloads(&crawler, dir)
+
+	root := crawler.Root()
+	req, _ := root.New(http.MethodGet, srv.URL+"/file.pdf")
+	partial := dm.partialPath(req)
+	os.MkdirAll(filepath.Dir(partial), 0o755)
+	os.WriteFile(partial, content[:1000], 0o644)
+	os.WriteFile(partial+".validator", []byte(`"v1"`), 0o644)
+
+	var visitErr error
+	crawler.OnError(func(r *Request, err error) {
+		visitErr = err
+	})
+	crawler.Run(srv.URL + "/file.pdf")
+
+	if !errors.Is(visitErr, ErrRangeMismatch) {
+		t.Fatalf("err = %v, want %v", visitErr, ErrRangeMismatch)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, ".partial")); len(entries) != 0 {
+		t.Fatalf("partial files = %d, want 0", len(entries))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("files = %d, want only partial directory", len(entries))
+	}
+}
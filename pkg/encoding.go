@@ -0,0 +1,132 @@
+package hopper
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	DefaultAcceptEncoding = "gzip, deflate, br, zstd"
+
+	// maxDrain is how much of unread body is discarded, so that
+	// connection can be reused.
+	maxDrain = 256 << 10
+)
+
+var ErrUnsupportedEncoding = errors.New("Unsupported content encoding")
+
+// decoders create readers which decode content encodings.
+var decoders = map[string]func(io.Reader) (io.ReadCloser, error){
+	"gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"x-gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"deflate": func(r io.Reader) (io.ReadCloser, error) {
+		// Deflate is meant to be zlib wrapped, but some servers send raw
+		// deflate stream.
+		br := bufio.NewReader(r)
+		header, err := br.Peek(2)
+		if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	},
+	"br": func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(r)), nil
+	},
+	"zstd": func(r io.Reader) (io.ReadCloser, error) {
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	},
+}
+
+// decodedBody reads decoded body, raw is body as it was received.
+type decodedBody struct {
+	io.Reader
+	decoders []io.ReadCloser
+	raw      io.ReadCloser
+}
+
+func (db *decodedBody) Close() error {
+	for _, decoder := range db.decoders {
+		decoder.Close()
+	}
+	return db.raw.Close()
+}
+
+// decodeBody replaces body of response with its decoded content, when it
+// has content encoding.
+func decodeBody(res *http.Response) error {
+	if res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified || res.ContentLength == 0 ||
+		(res.Request != nil && res.Request.Method == http.MethodHead) {
+		return nil
+	}
+
+	encodings := []string{}
+	for _, value := range res.Header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+	if len(encodings) == 0 {
+		return nil
+	}
+
+	body := &decodedBody{Reader: res.Body, raw: res.Body}
+	// Encodings are listed in order they were applied.
+	for i := len(encodings) - 1; i >= 0; i-- {
+		decoder, ok := decoders[encodings[i]]
+		if !ok {
+			body.Close()
+			return fmt.Errorf("Encoding %s: %w", encodings[i], ErrUnsupportedEncoding)
+		}
+
+		reader, err := decoder(body.Reader)
+		if err != nil {
+			body.Close()
+			return fmt.Errorf("Encoding %s: %w", encodings[i], err)
+		}
+		body.Reader = reader
+		body.decoders = append(body.decoders, reader)
+	}
+
+	res.Body = body
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	return nil
+}
+
+// rawBody returns body as it was received, before decoding.
+func rawBody(body io.ReadCloser) io.ReadCloser {
+	if decoded, ok := body.(*decodedBody); ok {
+		return decoded.raw
+	}
+	return body
+}
+
+// drainBody discards rest of body up to maxDrain and closes it.
+func drainBody(body io.ReadCloser) {
+	io.CopyN(io.Discard, rawBody(body), maxDrain)
+	body.Close()
+}
@@ -0,0 +1,133 @@
+package hopper
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestResponseBody(t *testing.T) {
+	content := strings.Repeat("hopper ", 1000)
+
+	encode := func(encoding string, data []byte) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		case "rawdeflate":
+			w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		case "br":
+			w = brotli.NewWriter(&buf)
+		case "zstd":
+			w, _ = zstd.NewWriter(&buf)
+		}
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings := r.URL.Query().Get("encoding")
+		data := []byte(content)
+		for _, encoding := range strings.Split(encodings, ",") {
+			if encoding != "" {
+				data = encode(encoding, data)
+			}
+		}
+		w.Header().Set("Content-Encoding", strings.ReplaceAll(encodings, "rawdeflate", "deflate"))
+
+		if r.URL.Query().Has("slow") {
+			w.Write(data[:10])
+			w.(http.Flusher).Flush()
+			time.Sleep(time.Second)
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	client := &Client{Client: &http.Client{}}
+	client.Init()
+
+	tests := []struct {
+		Name      string
+		Query     string
+		Limit     int64
+		Timeout   time.Duration
+		Want      int
+		Truncated bool
+	}{
+		{"Identity", "", 0, 0, len(content), false},
+		{"Gzip", "encoding=gzip", 0, 0, len(content), false},
+		{"Deflate", "encoding=deflate", 0, 0, len(content), false},
+		{"RawDeflate", "encoding=rawdeflate", 0, 0, len(content), false},
+		{"Brotli", "encoding=br", 0, 0, len(content), false},
+		{"Zstd", "encoding=zstd", 0, 0, len(content), false},
+		{"Chained", "encoding=gzip,br", 0, 0, len(content), false},
+		{"Limit", "encoding=gzip", 100, 0, 100, true},
+		{"Timeout", "slow=1", 0, 100 * time.Millisecond, 10, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			req := &Request{}
+			req.Init()
+			req.Method = http.MethodGet
+			req.URL, _ = url.Parse(srv.URL + "/?" + test.Query)
+			req.Properties["ContentLength"] = test.Limit
+			req.Properties["BodyTimeout"] = test.Timeout
+
+			httpRes, err := client.DoRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := NewResponse(httpRes, req.Properties, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Close()
+
+			body, err := res.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(body) != test.Want || res.Truncated != test.Truncated || string(body) != content[:len(body)] {
+				t.Fatalf("Response.Bytes = %d bytes truncated %t, want %d bytes truncated %t", len(body), res.Truncated, test.Want, test.Truncated)
+			}
+		})
+	}
+
+	t.Run("ReusesConnection", func(t *testing.T) {
+		reused := false
+		trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused }}
+
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, srv.URL, nil)
+			httpRes, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := &Response{Properties: map[string]any{"ContentLength": int64(10)}, raw: httpRes.Body}
+			res.Bytes()
+		}
+
+		if !reused {
+			t.Fatalf("reused = false, want true")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package hopper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+var ErrUnknownFetcher = errors.New("Unknown fetcher")
+
+// Fetcher fetches response of request. Client fetches responses over
+// plain http, rendering fetchers return documents rendered by browser.
+type Fetcher interface {
+	Fetch(ctx context.Context, req *Request) (*Response, error)
+}
+
+// RedirectError is returned by fetcher for redirect which was not
+// followed. Its target is crawled as new request.
+type RedirectError struct {
+	Location   *url.URL
+	StatusCode int
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("Redirect %d to %s was not followed", e.StatusCode, e.Location)
+}
+
+// Fetch sends request and returns its response.
+func (c *Client) Fetch(ctx context.Context, req *Request) (*Response, error) {
+	httpRes, err := c.doRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if location, ok := redirectLocation(httpRes); ok {
+		drainBody(httpRes.Body)
+		return nil, &RedirectError{Location: location, StatusCode: httpRes.StatusCode}
+	}
+
+	res, err := NewResponse(httpRes, req.Properties, req)
+	if err != nil {
+		drainBody(httpRes.Body)
+		return nil, err
+	}
+	return res, nil
+}
+
+// fetcher returns fetcher named by request, client if it names none.
+func (c *Crawler) fetcher(req *Request) (Fetcher, error) {
+	if req.Fetcher == "" {
+		return c.client, nil
+	}
+
+	fetcher, exists := c.Fetchers[req.Fetcher]
+	if !exists {
+		return nil, fmt.Errorf("Fetcher %s: %w", req.Fetcher, ErrUnknownFetcher)
+	}
+	return fetcher, nil
+}
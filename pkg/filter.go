@@ -0,0 +1,215 @@
+package hopper
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var ErrFiltered = errors.New("Filtered url")
+
+// Filter decides whether url should be crawled. It returns error
+// wrapping ErrFiltered which describes why url was rejected.
+type Filter func(*url.URL) error
+
+// AllowDomains accepts only urls with hostname matching one of domains.
+// Domain prefixed with "*." matches any of its subdomains. Domains are
+// matched regardless of case and trailing dot.
+func AllowDomains(domains ...string) Filter {
+	domains = normalizeDomains(domains)
+
+	return func(uri *url.URL) error {
+		if len(domains) == 0 || matchDomain(uri.Hostname(), domains) {
+			return nil
+		}
+		return fmt.Errorf("Host %s not in allowed domains: %w", uri.Hostname(), ErrFiltered)
+	}
+}
+
+// DisallowDomains rejects urls with hostname matching one of domains.
+func DisallowDomains(domains ...string) Filter {
+	domains = normalizeDomains(domains)
+
+	return func(uri *url.URL) error {
+		if matchDomain(uri.Hostname(), domains) {
+			return fmt.Errorf("Host %s in disallowed domains: %w", uri.Hostname(), ErrFiltered)
+		}
+		return nil
+	}
+}
+
+// AllowRegistrableDomains accepts urls which share registrable domain
+// (eTLD+1 according to public suffix list) with one of domains.
+func AllowRegistrableDomains(domains ...string) Filter {
+	allowed := map[string]bool{}
+	for _, domain := range domains {
+		allowed[registrableDomain(domain)] = true
+	}
+
+	return func(uri *url.URL) error {
+		if allowed[registrableDomain(uri.Hostname())] {
+			return nil
+		}
+		return fmt.Errorf("Host %s not in allowed registrable domains: %w", uri.Hostname(), ErrFiltered)
+	}
+}
+
+// AllowRegexp accepts only urls matching at least one of patterns.
+func AllowRegexp(patterns ...*regexp.Regexp) Filter {
+	return func(uri *url.URL) error {
+		for _, pattern := range patterns {
+			if pattern.MatchString(uri.String()) {
+				return nil
+			}
+		}
+		return fmt.Errorf("URL %s does not match allowed patterns: %w", uri, ErrFiltered)
+	}
+}
+
+// DisallowRegexp rejects urls matching any of patterns.
+func DisallowRegexp(patterns ...*regexp.Regexp) Filter {
+	return func(uri *url.URL) error {
+		for _, pattern := range patterns {
+			if pattern.MatchString(uri.String()) {
+				return fmt.Errorf("URL %s matches disallowed pattern %s: %w", uri, pattern, ErrFiltered)
+			}
+		}
+		return nil
+	}
+}
+
+// AllowPaths accepts only urls with path matching one of globs. In globs
+// "*" matches within single path segment and "**" across segments.
+func AllowPaths(globs ...string) Filter {
+	patterns := compileGlobs(globs)
+
+	return func(uri *url.URL) error {
+		for _, pattern := range patterns {
+			if pattern.MatchString(uri.EscapedPath()) {
+				return nil
+			}
+		}
+		return fmt.Errorf("Path %s does not match allowed paths: %w", uri.EscapedPath(), ErrFiltered)
+	}
+}
+
+// DisallowPaths rejects urls with path matching any of globs.
+func DisallowPaths(globs ...string) Filter {
+	patterns := compileGlobs(globs)
+
+	return func(uri *url.URL) error {
+		for i, pattern := range patterns {
+			if pattern.MatchString(uri.EscapedPath()) {
+				return fmt.Errorf("Path %s matches disallowed path %s: %w", uri.EscapedPath(), globs[i], ErrFiltered)
+			}
+		}
+		return nil
+	}
+}
+
+// DisallowQueryParams rejects urls containing any of query keys.
+// Keys can contain path.Match wildcards, e.g. "session*".
+func DisallowQueryParams(keys ...string) Filter {
+	return func(uri *url.URL) error {
+		for key := range uri.Query() {
+			for _, pattern := range keys {
+				if matched, _ := path.Match(pattern, key); matched {
+					return fmt.Errorf("Query parameter %s is disallowed: %w", key, ErrFiltered)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// MaxQueryParams rejects urls with more than max query parameters.
+func MaxQueryParams(max int) Filter {
+	return func(uri *url.URL) error {
+		if n := len(uri.Query()); n > max {
+			return fmt.Errorf("URL has %d query parameters, allowed %d: %w", n, max, ErrFiltered)
+		}
+		return nil
+	}
+}
+
+// DisallowExtensions rejects urls whose path ends with one of file extensions.
+func DisallowExtensions(exts ...string) Filter {
+	disallowed := map[string]bool{}
+	for _, ext := range exts {
+		disallowed["."+strings.TrimPrefix(strings.ToLower(ext), ".")] = true
+	}
+
+	return func(uri *url.URL) error {
+		ext := strings.ToLower(path.Ext(uri.Path))
+		if disallowed[ext] {
+			return fmt.Errorf("Extension %s is disallowed: %w", ext, ErrFiltered)
+		}
+		return nil
+	}
+}
+
+// normalizeDomains returns domains lowercased and without trailing dot,
+// as hosts of normalized urls are.
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, len(domains))
+	for i, domain := range domains {
+		normalized[i] = strings.TrimSuffix(strings.ToLower(domain), ".")
+	}
+	return normalized
+}
+
+func matchDomain(host string, domains []string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, domain := range domains {
+		if suffix, wildcard := strings.CutPrefix(domain, "*."); wildcard {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == domain {
+			return true
+		}
+	}
+
+	return false
+}
+
+// registrableDomain returns eTLD+1 of host, or host itself when it
+// has none (e.g. ip addresses and public suffixes).
+func registrableDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(host))
+	if err != nil {
+		return strings.ToLower(host)
+	}
+
+	return domain
+}
+
+func compileGlobs(globs []string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(globs))
+	for i, glob := range globs {
+		var b strings.Builder
+		b.WriteString("^")
+		for j := 0; j < len(glob); j++ {
+			switch {
+			case strings.HasPrefix(glob[j:], "**"):
+				b.WriteString(".*")
+				j++
+			case glob[j] == '*':
+				b.WriteString("[^/]*")
+			case glob[j] == '?':
+				b.WriteString("[^/]")
+			default:
+				b.WriteString(regexp.QuoteMeta(glob[j : j+1]))
+			}
+		}
+		b.WriteString("$")
+		patterns[i] = regexp.MustCompile(b.String())
+	}
+
+	return patterns
+}
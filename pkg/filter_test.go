@@ -0,0 +1,133 @@
+package hopper
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Filter Filter
+		URL    string
+		Allow  bool
+	}{
+		{"AllowDomainsMultiple", AllowDomains("a.com", "b.com"), "http://b.com/", true},
+		{"AllowDomainsRejected", AllowDomains("a.com", "b.com"), "http://c.com/", false},
+		{"AllowDomainsWildcard", AllowDomains("*.a.com"), "http://docs.a.com/", true},
+		{"AllowDomainsWildcardApex", AllowDomains("*.a.com"), "http://a.com/", false},
+		{"DisallowDomains", DisallowDomains("*.ads.com"), "http://x.ads.com/", false},
+		{"AllowDomainsCase", AllowDomains("A.com."), "http://a.com/", true},
+		{"DisallowDomainsCase", DisallowDomains("*.Ads.com"), "http://x.ads.com/", false},
+		{"RegistrableDomain", AllowRegistrableDomains("www.example.co.uk"), "http://shop.example.co.uk/", true},
+		{"RegistrableDomainRejected", AllowRegistrableDomains("example.co.uk"), "http://other.co.uk/", false},
+		{"AllowRegexp", AllowRegexp(regexp.MustCompile(`/wiki/`)), "http://a.com/wiki/Go", true},
+		{"DisallowRegexp", DisallowRegexp(regexp.MustCompile(`action=edit`)), "http://a.com/?action=edit", false},
+		{"AllowPaths", AllowPaths("/blog/*"), "http://a.com/blog/post", true},
+		{"AllowPathsNested", AllowPaths("/blog/*"), "http://a.com/blog/2023/post", false},
+		{"AllowPathsRecursive", AllowPaths("/blog/**"), "http://a.com/blog/2023/post", true},
+		{"DisallowPaths", DisallowPaths("/private/**"), "http://a.com/private/a/b", false},
+		{"DisallowQueryParams", DisallowQueryParams("session*"), "http://a.com/?sessionid=1", false},
+		{"MaxQueryParams", MaxQueryParams(1), "http://a.com/?a=1&b=2", false},
+		{"DisallowExtensions", DisallowExtensions("pdf", ".JPG"), "http://a.com/photo.jpg", false},
+		{"DisallowExtensionsAllowed", DisallowExtensions("pdf"), "http://a.com/page.html", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			uri, err := url.Parse(test.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = test.Filter(uri)
+			if test.Allow && err != nil {
+				t.Fatalf("filter(%s) = %s, want nil", test.URL, err)
+			}
+			if !test.Allow && !errors.Is(err, ErrFiltered) {
+				t.Fatalf("filter(%s) = %v, want ErrFiltered", test.URL, err)
+			}
+		})
+	}
+}
+
+func TestScope(t *testing.T) {
+	seed := &Request{}
+	seed.Init()
+	seed.Properties["AllowedDepth"] = 10
+	seed.Properties["Scope"] = ScopePrefix
+	seed.Properties["OffsiteHops"] = 1
+
+	root, err := seed.New("GET", "http://a.com/docs/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name   string
+		Parent *Request
+		URL    string
+		Hops   int
+	}{
+		{"InScope", root, "http://a.com/docs/guide/", 0},
+		{"OutsidePrefix", root, "http://a.com/blog/", 1},
+		{"OffSite", root, "http://b.com/", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			req, err := test.Parent.New("GET", test.URL)
+			if err != nil {
+				t.Fatalf("New(%s) = %s, want nil", test.URL, err)
+			}
+			if req.Hops != test.Hops {
+				t.Fatalf("Request.Hops = %d, want %d", req.Hops, test.Hops)
+			}
+		})
+	}
+
+	t.Run("SecondHop", func(t *testing.T) {
+		offsite, err := root.New("GET", "http://b.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := offsite.New("GET", "http://c.com/"); !errors.Is(err, ErrFiltered) {
+			t.Fatalf("New = %v, want ErrFiltered", err)
+		}
+		if back, err := offsite.New("GET", "http://a.com/docs/a"); err != nil || back.Hops != 0 {
+			t.Fatalf("New = %v, want request with 0 hops", err)
+		}
+	})
+}
+
+func TestRejectedLinks(t *testing.T) {
+	seed := &Request{}
+	seed.Init()
+	seed.Properties["AllowedDepth"] = 10
+	seed.Properties["Filters"] = []Filter{AllowDomains("a.com")}
+
+	req, _ := seed.New(http.MethodGet, "http://a.com/")
+	httpRes := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/html"}},
+		Body:       io.NopCloser(strings.NewReader(`<a href="/ok">Ok</a><a href="http://b.com/">Other</a><link rel="stylesheet" href="http://b.com/site.css">`)),
+	}
+	res, err := NewResponse(httpRes, req.Properties, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discovered, err := res.Do()
+	if len(discovered) != 1 || discovered[0].URL.Path != "/ok" {
+		t.Fatalf("Response.Do() = %v, want /ok", discovered)
+	}
+	if !errors.Is(err, ErrFiltered) || !strings.Contains(err.Error(), "Link http://b.com/:") || !strings.Contains(err.Error(), "Link http://b.com/site.css:") {
+		t.Fatalf("err = %v, want both b.com links rejected", err)
+	}
+}
@@ -0,0 +1,227 @@
+package hopper
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultShingleSize      = 3
+	DefaultSimHashThreshold = 3
+)
+
+var ErrDuplicateContent = errors.New("Duplicate content")
+
+// Fingerprint identifies content of a page. Exact is SHA-256 of normalised
+// text and SimHash is locality sensitive hash over word shingles.
+type Fingerprint struct {
+	Exact   [sha256.Size]byte
+	SimHash uint64
+}
+
+// NewFingerprint computes fingerprint of html body.
+func NewFingerprint(body []byte) *Fingerprint {
+	text := strings.ToLower(ExtractText(body))
+
+	return &Fingerprint{
+		Exact:   sha256.Sum256([]byte(text)),
+		SimHash: SimHash(Tokenize(text), DefaultShingleSize),
+	}
+}
+
+// Distance returns number of differing bits between SimHashes.
+func (f *Fingerprint) Distance(other *Fingerprint) int {
+	return bits.OnesCount64(f.SimHash ^ other.SimHash)
+}
+
+// SimHash computes 64-bit SimHash over shingles of given size.
+func SimHash(tokens []string, size int) uint64 {
+	if len(tokens) < size {
+		size = len(tokens)
+	}
+
+	var weights [64]int
+	for i := 0; i+size <= len(tokens) && size > 0; i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(tokens[i:i+size], " ")))
+		sum := h.Sum64()
+
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var hash uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			hash |= 1 << bit
+		}
+	}
+
+	return hash
+}
+
+type fingerprintEntry struct {
+	simHash uint64
+	uri     *url.URL
+}
+
+// FingerprintIndex remembers fingerprints of seen pages. Near duplicates are
+// found by splitting SimHash into Threshold+1 bands, at least one of which
+// must match exactly for hashes within Threshold bits.
+type FingerprintIndex struct {
+	sync.Mutex
+
+	Threshold int
+
+	exact map[[sha256.Size]byte]*url.URL
+	bands []map[uint64][]fingerprintEntry
+	// last is latest fingerprint of each url, replaced on its revisits.
+	last map[string]*Fingerprint
+}
+
+func (fi *FingerprintIndex) Init() {
+	if fi.Threshold < 0 {
+		fi.Threshold = 0
+	}
+	if fi.Threshold > 63 {
+		fi.Threshold = 63
+	}
+
+	fi.exact = map[[sha256.Size]byte]*url.URL{}
+	fi.last = map[string]*Fingerprint{}
+	fi.bands = make([]map[uint64][]fingerprintEntry, fi.Threshold+1)
+	for i := range fi.bands {
+		fi.bands[i] = map[uint64][]fingerprintEntry{}
+	}
+}
+
+// Add records fingerprint of uri. If content was seen before under
+// different url it returns that original url instead. Revisited url is
+// never duplicate of itself and its changed content replaces previous
+// fingerprint.
+func (fi *FingerprintIndex) Add(uri *url.URL, fp *Fingerprint) *url.URL {
+	fi.Lock()
+	defer fi.Unlock()
+
+	if original, exists := fi.exact[fp.Exact]; exists {
+		if original.String() == uri.String() {
+			return nil
+		}
+		return original
+	}
+	if last, exists := fi.last[uri.String()]; exists {
+		fi.remove(uri, last)
+		delete(fi.last, uri.String())
+	}
+
+	for i, band := range fi.bands {
+		for _, entry := range band[fi.band(fp.SimHash, i)] {
+			if bits.OnesCount64(entry.simHash^fp.SimHash) <= fi.Threshold && entry.uri.String() != uri.String() {
+				return entry.uri
+			}
+		}
+	}
+
+	fi.exact[fp.Exact] = uri
+	fi.last[uri.String()] = fp
+	for i, band := range fi.bands {
+		key := fi.band(fp.SimHash, i)
+		band[key] = append(band[key], fingerprintEntry{simHash: fp.SimHash, uri: uri})
+	}
+
+	return nil
+}
+
+// remove deletes entries of fingerprint recorded for uri.
+func (fi *FingerprintIndex) remove(uri *url.URL, fp *Fingerprint) {
+	if original, exists := fi.exact[fp.Exact]; exists && original.String() == uri.String() {
+		delete(fi.exact, fp.Exact)
+	}
+
+	for i, band := range fi.bands {
+		key := fi.band(fp.SimHash, i)
+		entries := band[key][:0]
+		for _, entry := range band[key] {
+			if entry.uri.String() != uri.String() {
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) == 0 {
+			delete(band, key)
+		} else {
+			band[key] = entries
+		}
+	}
+}
+
+// band returns bits of hash belonging to i-th band.
+func (fi *FingerprintIndex) band(hash uint64, i int) uint64 {
+	n := len(fi.bands)
+	start := i * 64 / n
+	end := (i + 1) * 64 / n
+
+	return (hash >> start) & (1<<(end-start) - 1)
+}
+
+type DuplicateAction int
+
+const (
+	// DropDuplicates stops processing of duplicate response with ErrDuplicateContent.
+	DropDuplicates DuplicateAction = iota
+	// NoFollowDuplicates passes duplicate to handlers but does not follow its links.
+	NoFollowDuplicates
+)
+
+type DuplicatesMiddleware struct {
+	Index  *FingerprintIndex
+	Action DuplicateAction
+}
+
+// Duplicates fingerprints every response and handles ones with content
+// already seen under different url. It should be registered before handlers
+// that are not interested in duplicates.
+func Duplicates(crawler *Crawler, action DuplicateAction) *DuplicatesMiddleware {
+	dm := &DuplicatesMiddleware{
+		Index:  &FingerprintIndex{Threshold: DefaultSimHashThreshold},
+		Action: action,
+	}
+	dm.Index.Init()
+
+	crawler.OnResponse(func(r *Response) error {
+		if r.NotModified {
+			return nil
+		}
+
+		body, err := r.Bytes()
+		if err != nil {
+			return fmt.Errorf("Duplicate: %w", err)
+		}
+
+		r.Fingerprint = NewFingerprint(body)
+		original := dm.Index.Add(r.Request.URL, r.Fingerprint)
+		if original == nil {
+			return nil
+		}
+
+		r.DuplicateOf = original
+		if dm.Action == NoFollowDuplicates {
+			r.NoFollow = true
+			return nil
+		}
+
+		return fmt.Errorf("Duplicate: %s: %w", original, ErrDuplicateContent)
+	})
+
+	return dm
+}
@@ -0,0 +1,88 @@
+package hopper
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFingerprintIndex(t *testing.T) {
+	article := strings.Repeat("the quick brown fox jumps over the lazy dog and keeps running through the forest ", 20)
+	pages := map[string]string{
+		"original":  "<html><body><p>" + article + "</p></body></html>",
+		"exact":     "<html><head><script>var session = 1</script></head><body><div>" + article + "</div></body></html>",
+		"near":      "<html><body><p>" + article + "</p><p>printed on monday</p></body></html>",
+		"different": "<html><body><p>" + strings.Repeat("completely unrelated text about cooking pasta with tomatoes ", 20) + "</p></body></html>",
+	}
+
+	index := &FingerprintIndex{Threshold: DefaultSimHashThreshold}
+	index.Init()
+
+	original, _ := url.Parse("http://example.com/original")
+	if dup := index.Add(original, NewFingerprint([]byte(pages["original"]))); dup != nil {
+		t.Fatalf("index.Add = %s, want nil", dup)
+	}
+
+	tests := []struct {
+		Name      string
+		Duplicate bool
+	}{
+		{"exact", true},
+		{"near", true},
+		{"different", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			uri, _ := url.Parse("http://example.com/" + test.Name)
+			dup := index.Add(uri, NewFingerprint([]byte(pages[test.Name])))
+
+			if test.Duplicate && (dup == nil || dup.String() != original.String()) {
+				t.Fatalf("index.Add = %v, want %s", dup, original)
+			}
+			if !test.Duplicate && dup != nil {
+				t.Fatalf("index.Add = %s, want nil", dup)
+			}
+		})
+	}
+
+	for _, name := range []string{"original", "near"} {
+		if dup := index.Add(original, NewFingerprint([]byte(pages[name]))); dup != nil {
+			t.Fatalf("index.Add(%s) of revisited page = %s, want nil", name, dup)
+		}
+	}
+}
+
+func TestFingerprintIndexRevisit(t *testing.T) {
+	fox := "<html><body><p>" + strings.Repeat("the quick brown fox jumps over the lazy dog and keeps running through the forest ", 20) + "</p></body></html>"
+	pasta := "<html><body><p>" + strings.Repeat("completely unrelated text about cooking pasta with tomatoes ", 20) + "</p></body></html>"
+
+	index := &FingerprintIndex{Threshold: DefaultSimHashThreshold}
+	index.Init()
+
+	page, _ := url.Parse("http://example.com/page")
+	other, _ := url.Parse("http://example.com/other")
+	for _, content := range []string{fox, pasta} {
+		if dup := index.Add(page, NewFingerprint([]byte(content))); dup != nil {
+			t.Fatalf("index.Add of revisited page = %s, want nil", dup)
+		}
+	}
+
+	entries := 0
+	for _, band := range index.bands {
+		for _, bucket := range band {
+			entries += len(bucket)
+		}
+	}
+	if len(index.exact) != 1 || entries != len(index.bands) {
+		t.Fatalf("index holds %d exact and %d band entries, want 1 and %d", len(index.exact), entries, len(index.bands))
+	}
+
+	// Page no longer has its old content.
+	if dup := index.Add(other, NewFingerprint([]byte(fox))); dup != nil {
+		t.Fatalf("index.Add of old content = %s, want nil", dup)
+	}
+	if dup := index.Add(other, NewFingerprint([]byte(pasta))); dup == nil || dup.String() != page.String() {
+		t.Fatalf("index.Add of current content = %v, want %s", dup, page)
+	}
+}
@@ -0,0 +1,281 @@
+package hopper
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Form is submittable state of html form element, with values it would
+// be submitted with by default.
+type Form struct {
+	Node    *html.Node
+	Action  *url.URL
+	Method  string
+	Enctype string
+	Values  url.Values
+}
+
+// ParseForms returns all forms of document with their default values,
+// actions resolved against base.
+func ParseForms(base *url.URL, root *html.Node) []*Form {
+	forms := []*Form{}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "form" {
+			forms = append(forms, parseForm(base, n))
+			return
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			f(ch)
+		}
+	}
+	f(root)
+
+	return forms
+}
+
+func parseForm(base *url.URL, node *html.Node) *Form {
+	form := &Form{
+		Node:    node,
+		Action:  base,
+		Method:  strings.ToUpper(attribute(node, "method")),
+		Enctype: strings.ToLower(attribute(node, "enctype")),
+		Values:  url.Values{},
+	}
+	if form.Method != "POST" {
+		form.Method = "GET"
+	}
+	if action := attribute(node, "action"); action != "" {
+		if resolved, err := base.Parse(action); err == nil {
+			form.Action = resolved
+		}
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			name := attribute(n, "name")
+			switch {
+			case name == "" || hasAttribute(n, "disabled"):
+			case n.Data == "input":
+				inputType := strings.ToLower(attribute(n, "type"))
+				switch inputType {
+				case "submit", "button", "image", "reset", "file":
+				case "checkbox", "radio":
+					if hasAttribute(n, "checked") {
+						value := attribute(n, "value")
+						if value == "" {
+							value = "on"
+						}
+						form.Values.Add(name, value)
+					}
+				default:
+					form.Values.Add(name, attribute(n, "value"))
+				}
+			case n.Data == "textarea":
+				form.Values.Add(name, nodeText(n))
+			case n.Data == "select":
+				if value, ok := selectedOption(n); ok {
+					form.Values.Add(name, value)
+				}
+			}
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			f(ch)
+		}
+	}
+	f(node)
+
+	return form
+}
+
+// Request creates request submitting form with its Values, as discovered
+// from base.
+func (f *Form) Request(base *Request) (*Request, error) {
+	action, body, err := f.submission()
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		return base.NewPost(action.String(), body)
+	}
+	return base.New(http.MethodGet, action.String())
+}
+
+// submission returns url and body form is submitted with.
+func (f *Form) submission() (*url.URL, *Body, error) {
+	if f.Method != http.MethodPost {
+		action := *f.Action
+		action.RawQuery = f.Values.Encode()
+		return &action, nil, nil
+	}
+
+	if f.Enctype == "multipart/form-data" {
+		body, err := MultipartBody(f.Values)
+		return f.Action, body, err
+	}
+	return f.Action, FormBody(f.Values), nil
+}
+
+// Forms returns forms of html response, actions resolved against its
+// final url.
+func (res *Response) Forms() ([]*Form, error) {
+	body, err := res.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	node, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return ParseForms(res.URL, node), nil
+}
+
+// selectedOption returns value of selected option, or first one.
+func selectedOption(n *html.Node) (string, bool) {
+	var first, selected *html.Node
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "option" {
+			if first == nil {
+				first = n
+			}
+			if selected == nil && hasAttribute(n, "selected") {
+				selected = n
+			}
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			f(ch)
+		}
+	}
+	f(n)
+
+	if selected == nil {
+		selected = first
+	}
+	if selected == nil {
+		return "", false
+	}
+	if hasAttribute(selected, "value") {
+		return attribute(selected, "value"), true
+	}
+	return nodeText(selected), true
+}
+
+// findSelector returns first node matching selector. Only simple
+// selectors are supported: compounds of tag, #id, .class, [attr] and
+// [attr=value] combined with descendant combinator.
+func findSelector(root *html.Node, selector string) *html.Node {
+	compounds := strings.Fields(selector)
+	if len(compounds) == 0 {
+		return nil
+	}
+
+	var found *html.Node
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if found != nil {
+			return
+		}
+		if n.Type == html.ElementNode && matchSelector(n, compounds) {
+			found = n
+			return
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			f(ch)
+		}
+	}
+	f(root)
+
+	return found
+}
+
+func matchSelector(n *html.Node, compounds []string) bool {
+	if !matchCompound(n, compounds[len(compounds)-1]) {
+		return false
+	}
+
+	rest := compounds[:len(compounds)-1]
+	for p := n.Parent; p != nil && len(rest) > 0; p = p.Parent {
+		if p.Type == html.ElementNode && matchCompound(p, rest[len(rest)-1]) {
+			rest = rest[:len(rest)-1]
+		}
+	}
+
+	return len(rest) == 0
+}
+
+func matchCompound(n *html.Node, compound string) bool {
+	for compound != "" {
+		end := strings.IndexAny(compound[1:], "#.[") + 1
+		if end == 0 {
+			end = len(compound)
+		}
+		part := compound[:end]
+		compound = compound[end:]
+
+		switch part[0] {
+		case '#':
+			if attribute(n, "id") != part[1:] {
+				return false
+			}
+		case '.':
+			if !containsField(attribute(n, "class"), part[1:]) {
+				return false
+			}
+		case '[':
+			// Attribute value may itself contain selector characters.
+			if !strings.HasSuffix(part, "]") {
+				closing := strings.Index(compound, "]")
+				if closing < 0 {
+					return false
+				}
+				part += compound[:closing+1]
+				compound = compound[closing+1:]
+			}
+			name, value, hasValue := strings.Cut(strings.Trim(part, "[]"), "=")
+			if !hasAttribute(n, name) || (hasValue && attribute(n, name) != strings.Trim(value, `"'`)) {
+				return false
+			}
+		default:
+			if part != "*" && !strings.EqualFold(n.Data, part) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func attribute(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func hasAttribute(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func containsField(s string, field string) bool {
+	for _, f := range strings.Fields(s) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package hopper
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestForms(t *testing.T) {
+	page := `<form action="/search"><input name="q" value="go"><select name="sort"><option value="new">New</option><option value="top" selected>Top</option></select>` +
+		`<input type="checkbox" name="exact"><input type="submit" name="go" value="Search"></form>` +
+		`<form method="post" enctype="multipart/form-data" action="upload"><input name="title" value="draft"><input name="off" disabled></form>`
+
+	tests := []struct {
+		Name        string
+		SubmitForms bool
+		Want        []string
+	}{
+		{"Disabled", false, []string{}},
+		{"Submitted", true, []string{"GET http://example.com/search?q=go&sort=top"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			res, discovered := discover(t, "http://example.com/docs/", "text/html", page, map[string]any{"SubmitForms": test.SubmitForms})
+			got := []string{}
+			for _, r := range discovered {
+				got = append(got, r.Method+" "+r.URL.String())
+			}
+			if strings.Join(got, ",") != strings.Join(test.Want, ",") {
+				t.Fatalf("Response.Do() = %v, want %v", got, test.Want)
+			}
+
+			forms, err := res.Forms()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(forms) != 2 {
+				t.Fatalf("Response.Forms() = %d forms, want 2", len(forms))
+			}
+
+			forms[1].Values.Set("title", "final")
+			upload, err := forms[1].Request(res.Request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if upload.Method != http.MethodPost || upload.URL.String() != "http://example.com/docs/upload" || !strings.HasPrefix(upload.Body.ContentType, "multipart/form-data") || !strings.Contains(string(upload.Body.Data), "final") || strings.Contains(string(upload.Body.Data), "off") {
+				t.Fatalf("Form.Request() = %s %s %s, want multipart post of title", upload.Method, upload.URL, upload.Body.ContentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package hopper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrGraphQL = errors.New("GraphQL error")
+
+// GraphQLQuery is query sent to GraphQL endpoint.
+type GraphQLQuery struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// WithVariable returns copy of query with variable set to value.
+func (q *GraphQLQuery) WithVariable(name string, value any) *GraphQLQuery {
+	variables := map[string]any{}
+	for k, v := range q.Variables {
+		variables[k] = v
+	}
+	variables[name] = value
+
+	return &GraphQLQuery{Query: q.Query, OperationName: q.OperationName, Variables: variables}
+}
+
+// NewGraphQL creates request which posts query to endpoint.
+func (req Request) NewGraphQL(endpoint string, query *GraphQLQuery) (*Request, error) {
+	body, err := JSONBody(query)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := req.NewPost(endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	created.GraphQL = query
+	return created, nil
+}
+
+// GraphQLError returns errors reported in GraphQL response.
+func (res *Response) GraphQLError() error {
+	doc, err := res.JSON()
+	if err != nil {
+		return err
+	}
+
+	obj, _ := doc.(map[string]any)
+	errs, _ := obj["errors"].([]any)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := []string{}
+	for _, e := range errs {
+		fields, _ := e.(map[string]any)
+		if message, ok := fields["message"].(string); ok {
+			messages = append(messages, message)
+		}
+	}
+	return fmt.Errorf("%s: %w", strings.Join(messages, "; "), ErrGraphQL)
+}
+
+// GraphQLCursor walks connection found at path of GraphQL response. While
+// its pageInfo has next page, query is sent again with cursor variable
+// set to endCursor.
+func GraphQLCursor(connection *JSONPath, variable string) Paginator {
+	return func(res *Response) (*Request, error) {
+		if res.Request.GraphQL == nil {
+			return nil, nil
+		}
+		if err := res.GraphQLError(); err != nil {
+			return nil, err
+		}
+
+		doc, err := res.JSON()
+		if err != nil {
+			return nil, err
+		}
+
+		connections := connection.Find(doc)
+		if len(connections) == 0 {
+			return nil, nil
+		}
+		conn, _ := connections[0].(map[string]any)
+		pageInfo, _ := conn["pageInfo"].(map[string]any)
+		if next, _ := pageInfo["hasNextPage"].(bool); !next {
+			return nil, nil
+		}
+		cursor, _ := pageInfo["endCursor"].(string)
+		if cursor == "" {
+			return nil, nil
+		}
+
+		// Pages are not deeper than the page they follow.
+		base := *res.Request
+		base.URL = res.URL
+		base.Depth--
+
+		return base.NewGraphQL(res.URL.String(), res.Request.GraphQL.WithVariable(variable, cursor))
+	}
+}
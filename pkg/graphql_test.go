@@ -0,0 +1,94 @@
+package hopper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestGraphQLCursor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var query GraphQLQuery
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&query) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch query.Variables["after"] {
+		case nil:
+			fmt.Fprint(w, `{"data": {"issues": {"nodes": [1, 2], "pageInfo": {"hasNextPage": true, "endCursor": "c2"}}}}`)
+		case "c2":
+			fmt.Fprint(w, `{"data": {"issues": {"nodes": [3], "pageInfo": {"hasNextPage": false, "endCursor": "c3"}}}}`)
+		default:
+			fmt.Fprint(w, `{"errors": [{"message": "invalid cursor"}]}`)
+		}
+	}))
+	defer srv.Close()
+
+	client := &Client{Client: &http.Client{}}
+	client.Init()
+
+	parent := &Request{}
+	parent.Init()
+	parent.URL, _ = url.Parse(srv.URL)
+	parent.Properties["AllowedDepth"] = 10
+	parent.Properties["ContentLength"] = int64(1 << 20)
+
+	query := &GraphQLQuery{
+		Query:     `query($after: String) { issues(after: $after) { nodes pageInfo { hasNextPage endCursor } } }`,
+		Variables: map[string]any{"first": 2},
+	}
+	paginator := GraphQLCursor(MustCompileJSONPath("$.data.issues"), "after")
+
+	tests := []struct {
+		Name  string
+		Query *GraphQLQuery
+		Want  any
+		Err   error
+	}{
+		{"FirstPage", query, "c2", nil},
+		{"LastPage", query.WithVariable("after", "c2"), nil, nil},
+		{"Error", query.WithVariable("after", "x"), nil, ErrGraphQL},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			req, err := parent.NewGraphQL("/graphql", test.Query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			httpRes, err := client.DoRequest(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := NewResponse(httpRes, req.Properties, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next, err := paginator(res)
+			if !errors.Is(err, test.Err) {
+				t.Fatalf("err = %v, want %v", err, test.Err)
+			}
+
+			var got any
+			if next != nil {
+				got = next.GraphQL.Variables["after"]
+				if next.Method != http.MethodPost || next.GraphQL.Variables["first"] != 2 {
+					t.Fatalf("Request = %s %v, want POST with first variable", next.Method, next.GraphQL.Variables)
+				}
+				if next.Key() == req.Key() {
+					t.Fatalf("Request.Key = %s, want key distinct from previous page", next.Key())
+				}
+			}
+			if got != test.Want {
+				t.Fatalf("after = %v, want %v", got, test.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package hopper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidJSONPath = errors.New("Invalid JSONPath")
+
+type jsonStep struct {
+	recursive bool
+	wildcard  bool
+	name      string
+	index     *int
+	slice     *[2]*int
+}
+
+// JSONPath selects values of decoded json document. Supported subset is
+// root $, child .name, ['name'] and .*, recursive descent ..name,
+// index [n], wildcard [*] and slice [start:end].
+type JSONPath struct {
+	expr  string
+	steps []jsonStep
+}
+
+// CompileJSONPath parses JSONPath expression.
+func CompileJSONPath(expr string) (*JSONPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("Path %s does not start at root: %w", expr, ErrInvalidJSONPath)
+	}
+
+	path := &JSONPath{expr: expr}
+	rest := expr[1:]
+	for rest != "" {
+		step := jsonStep{}
+		if strings.HasPrefix(rest, "..") {
+			step.recursive = true
+			rest = rest[2:]
+			if !strings.HasPrefix(rest, "[") {
+				rest = "." + rest
+			}
+		}
+
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("Empty name in %s: %w", expr, ErrInvalidJSONPath)
+			}
+			if rest[:end] == "*" {
+				step.wildcard = true
+			} else {
+				step.name = rest[:end]
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("Unclosed bracket in %s: %w", expr, ErrInvalidJSONPath)
+			}
+			if err := step.parseBracket(rest[1:end]); err != nil {
+				return nil, fmt.Errorf("Bracket %s in %s: %w", rest[:end+1], expr, err)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("Unexpected %q in %s: %w", rest[0], expr, ErrInvalidJSONPath)
+		}
+
+		path.steps = append(path.steps, step)
+	}
+
+	return path, nil
+}
+
+// MustCompileJSONPath is like CompileJSONPath but panics on error.
+func MustCompileJSONPath(expr string) *JSONPath {
+	path, err := CompileJSONPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+func (step *jsonStep) parseBracket(inner string) error {
+	inner = strings.TrimSpace(inner)
+
+	switch {
+	case inner == "*":
+		step.wildcard = true
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		step.name = inner[1 : len(inner)-1]
+	case strings.Contains(inner, ":"):
+		bounds := [2]*int{}
+		for i, part := range strings.SplitN(inner, ":", 2) {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return ErrInvalidJSONPath
+			}
+			bounds[i] = &n
+		}
+		step.slice = &bounds
+	default:
+		n, err := strconv.Atoi(inner)
+		if err != nil {
+			return ErrInvalidJSONPath
+		}
+		step.index = &n
+	}
+
+	return nil
+}
+
+func (p *JSONPath) String() string {
+	return p.expr
+}
+
+// Find returns all values of document selected by path.
+func (p *JSONPath) Find(doc any) []any {
+	nodes := []any{doc}
+	for _, step := range p.steps {
+		next := []any{}
+		for _, node := range nodes {
+			if step.recursive {
+				for _, descendant := range descendants(node) {
+					next = append(next, step.apply(descendant)...)
+				}
+			} else {
+				next = append(next, step.apply(node)...)
+			}
+		}
+		nodes = next
+	}
+
+	return nodes
+}
+
+// Strings returns selected values which are strings.
+func (p *JSONPath) Strings(doc any) []string {
+	strs := []string{}
+	for _, value := range p.Find(doc) {
+		if s, ok := value.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+func (step *jsonStep) apply(node any) []any {
+	switch {
+	case step.wildcard:
+		return children(node)
+	case step.index != nil:
+		arr, ok := node.([]any)
+		if !ok {
+			return nil
+		}
+		i := *step.index
+		if i < 0 {
+			i += len(arr)
+		}
+		if i < 0 || i >= len(arr) {
+			return nil
+		}
+		return []any{arr[i]}
+	case step.slice != nil:
+		arr, ok := node.([]any)
+		if !ok {
+			return nil
+		}
+		start, end := 0, len(arr)
+		if step.slice[0] != nil {
+			start = clampIndex(*step.slice[0], len(arr))
+		}
+		if step.slice[1] != nil {
+			end = clampIndex(*step.slice[1], len(arr))
+		}
+		if start >= end {
+			return nil
+		}
+		return append([]any{}, arr[start:end]...)
+	default:
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value, exists := obj[step.name]
+		if !exists {
+			return nil
+		}
+		return []any{value}
+	}
+}
+
+func clampIndex(i int, length int) int {
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
+// children returns values of object in order of keys, or array elements.
+func children(node any) []any {
+	switch v := node.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		values := make([]any, len(keys))
+		for i, key := range keys {
+			values[i] = v[key]
+		}
+		return values
+	case []any:
+		return v
+	default:
+		return nil
+	}
+}
+
+// descendants returns node and all nested values in document order.
+func descendants(node any) []any {
+	nodes := []any{node}
+	for _, child := range children(node) {
+		nodes = append(nodes, descendants(child)...)
+	}
+	return nodes
+}
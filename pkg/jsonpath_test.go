@@ -0,0 +1,46 @@
+package hopper
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	var doc any
+	json.Unmarshal([]byte(`{
+		"items": [{"url": "/a", "tags": ["x"]}, {"url": "/b"}, {"url": "/c", "child": {"url": "/d"}}],
+		"next": "/page/2",
+		"meta": {"total": 3}
+	}`), &doc)
+
+	tests := []struct {
+		Path string
+		Want string
+	}{
+		{"$.next", "[/page/2]"},
+		{"$['next']", "[/page/2]"},
+		{"$.items[*].url", "[/a /b /c]"},
+		{"$.items[0].url", "[/a]"},
+		{"$.items[-1].url", "[/c]"},
+		{"$.items[1:].url", "[/b /c]"},
+		{"$..url", "[/a /b /c /d]"},
+		{"$.meta.*", "[3]"},
+		{"$.missing.url", "[]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Path, func(t *testing.T) {
+			got := fmt.Sprint(MustCompileJSONPath(test.Path).Find(doc))
+			if got != test.Want {
+				t.Fatalf("JSONPath.Find = %s, want %s", got, test.Want)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"items", "$.", "$[0", "$[x]"} {
+		if _, err := CompileJSONPath(invalid); err == nil {
+			t.Fatalf("CompileJSONPath(%s) = nil, want error", invalid)
+		}
+	}
+}
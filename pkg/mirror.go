@@ -0,0 +1,417 @@
+package hopper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+var ErrTruncatedBody = errors.New("Body was truncated")
+
+// mirrorAttrs are attributes of elements which reference other urls.
+var mirrorAttrs = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"iframe": {"src"},
+	"frame":  {"src"},
+	"img":    {"src", "srcset"},
+	"source": {"src", "srcset"},
+	"script": {"src"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"track":  {"src"},
+	"embed":  {"src"},
+	"input":  {"src"},
+	"object": {"data"},
+}
+
+// mirrorPages are elements which link pages, other elements link assets
+// of page.
+var mirrorPages = map[string]bool{"a": true, "area": true, "iframe": true, "frame": true}
+
+// MirrorMiddleware writes pages and their assets under Dir as local copy
+// of site, which can be browsed offline. Files are laid out as host/path.
+// Directories are written as index.html, files without extension get
+// .html and query is added to name after @.
+//
+// Pages and stylesheets are written as they were received. Once crawl
+// finished, ConvertLinks rewrites their links to relative paths of files
+// which were mirrored and to absolute urls otherwise, like wget's
+// --convert-links does.
+type MirrorMiddleware struct {
+	sync.Mutex
+
+	Dir string
+
+	documents []mirrorDocument
+}
+
+// mirrorDocument is written file whose links are converted by ConvertLinks.
+type mirrorDocument struct {
+	local string
+	kind  string
+	req   *Request
+	url   *url.URL
+}
+
+// Mirror registers mirror mode on crawler. Stylesheets, scripts, images
+// and other assets of pages are crawled at depth of their page.
+func Mirror(crawler *Crawler, dir string) *MirrorMiddleware {
+	mm := &MirrorMiddleware{Dir: dir}
+
+	crawler.OnResponse(func(r *Response) error {
+		if r.NotModified || r.Download != nil || r.StatusCode != http.StatusOK {
+			return nil
+		}
+
+		if err := mm.Write(r); err != nil {
+			return fmt.Errorf("Mirror: %w", err)
+		}
+		return nil
+	})
+
+	return mm
+}
+
+// Write writes response under its final url and url it was requested
+// with, if it was redirected. Referenced assets are added to discovered
+// requests of response. Truncated responses are not written, so that
+// partial copies don't replace files.
+func (mm *MirrorMiddleware) Write(res *Response) error {
+	body, err := res.Bytes()
+	if err != nil {
+		return err
+	}
+	if res.Truncated {
+		return fmt.Errorf("%s: %w", res.URL, ErrTruncatedBody)
+	}
+
+	kind := mirrorType(res, body)
+	converted := kind == "text/html" || kind == "application/xhtml+xml" || kind == "text/css"
+	if converted {
+		discoverer := &mirrorConverter{req: res.Request, url: res.URL, local: mirrorPath(res.URL), discovered: &res.Discovered}
+		if _, err := discoverer.document(kind, body); err != nil {
+			return err
+		}
+	}
+
+	locations := []*url.URL{res.URL}
+	if res.Request.URL.String() != res.URL.String() {
+		locations = append(locations, res.Request.URL)
+	}
+
+	for _, location := range locations {
+		local := mirrorPath(location)
+		if err := writeFile(filepath.Join(mm.Dir, filepath.FromSlash(local)), body); err != nil {
+			return err
+		}
+		if converted {
+			mm.Lock()
+			mm.documents = append(mm.documents, mirrorDocument{local: local, kind: kind, req: res.Request, url: res.URL})
+			mm.Unlock()
+		}
+	}
+
+	return nil
+}
+
+// ConvertLinks rewrites links of pages and stylesheets written so far,
+// relative to location of each. It is called once crawl finished, when
+// it is known which targets were mirrored.
+func (mm *MirrorMiddleware) ConvertLinks() error {
+	mm.Lock()
+	defer mm.Unlock()
+
+	for _, document := range mm.documents {
+		name := filepath.Join(mm.Dir, filepath.FromSlash(document.local))
+		body, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		converter := &mirrorConverter{req: document.req, url: document.url, local: document.local, mirrored: mm.mirrored}
+		data, err := converter.document(document.kind, body)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(name, data); err != nil {
+			return err
+		}
+	}
+	mm.documents = nil
+
+	return nil
+}
+
+// mirrored reports whether file of url was written.
+func (mm *MirrorMiddleware) mirrored(u *url.URL) bool {
+	info, err := os.Stat(filepath.Join(mm.Dir, filepath.FromSlash(mirrorPath(u))))
+	return err == nil && !info.IsDir()
+}
+
+// mirrorConverter rewrites links of document of url written to local
+// path. Assets are added to discovered, if it is set, and links are
+// relative only to targets which are mirrored, if it is set.
+type mirrorConverter struct {
+	req        *Request
+	url        *url.URL
+	local      string
+	discovered *[]*Request
+	mirrored   func(u *url.URL) bool
+	base       *url.URL
+}
+
+// document rewrites links of html document or stylesheet.
+func (mc *mirrorConverter) document(kind string, body []byte) ([]byte, error) {
+	if kind == "text/css" {
+		return []byte(rewriteCSS(string(body), mc.asset)), nil
+	}
+	return mc.html(body)
+}
+
+// html rewrites links of html document. Base element is removed, as links
+// are resolved against it.
+func (mc *mirrorConverter) html(body []byte) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	mc.base = mc.url
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		for ch := n.FirstChild; ch != nil; {
+			next := ch.NextSibling
+			if ch.Type == html.ElementNode && ch.Data == "base" {
+				if href := attribute(ch, "href"); href != "" {
+					if resolved, err := mc.base.Parse(href); err == nil {
+						mc.base = resolved
+					}
+				}
+				n.RemoveChild(ch)
+			}
+			ch = next
+		}
+
+		if n.Type == html.ElementNode {
+			mc.element(n)
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			f(ch)
+		}
+	}
+	f(doc)
+
+	var b bytes.Buffer
+	if err := html.Render(&b, doc); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (mc *mirrorConverter) element(n *html.Node) {
+	convert := mc.asset
+	if mirrorPages[n.Data] {
+		convert = mc.page
+	}
+	// Only links loaded by browser are assets, others stay absolute.
+	if n.Data == "link" && !isAssetRel(n) {
+		convert = mc.absolute
+	}
+
+	for i, a := range n.Attr {
+		switch {
+		case a.Key == "style":
+			n.Attr[i].Val = rewriteCSS(a.Val, mc.asset)
+		case a.Key == "srcset":
+			n.Attr[i].Val = rewriteSrcset(a.Val, convert)
+		default:
+			for _, key := range mirrorAttrs[n.Data] {
+				if a.Key == key {
+					n.Attr[i].Val = convert(a.Val)
+				}
+			}
+		}
+	}
+
+	if n.Data == "style" {
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			if ch.Type == html.TextNode {
+				ch.Data = rewriteCSS(ch.Data, mc.asset)
+			}
+		}
+	}
+}
+
+// page converts link to page, which is crawled one level deeper.
+func (mc *mirrorConverter) page(uri string) string {
+	return mc.convert(uri, false)
+}
+
+// asset converts link to asset, which is crawled at depth of its page and
+// added to discovered requests.
+func (mc *mirrorConverter) asset(uri string) string {
+	return mc.convert(uri, true)
+}
+
+// absolute converts link to absolute url.
+func (mc *mirrorConverter) absolute(uri string) string {
+	resolved, ok := mc.resolve(uri)
+	if !ok {
+		return uri
+	}
+	return resolved.String()
+}
+
+func (mc *mirrorConverter) convert(uri string, asset bool) string {
+	resolved, ok := mc.resolve(uri)
+	if !ok {
+		return uri
+	}
+
+	base := *mc.req
+	base.URL = mc.base
+	if asset {
+		base.Depth--
+	}
+	req, err := base.New(http.MethodGet, resolved.String())
+	if err != nil {
+		return resolved.String()
+	}
+	if asset && mc.discovered != nil {
+		*mc.discovered = append(*mc.discovered, req)
+	}
+	// Targets rejected later, e.g. by robots, were not mirrored.
+	if mc.mirrored != nil && !mc.mirrored(req.URL) {
+		return resolved.String()
+	}
+
+	return relativePath(mc.local, mirrorPath(req.URL), resolved.Fragment)
+}
+
+// resolve resolves http link against base of document. Fragments within
+// document and other schemes are not resolved.
+func (mc *mirrorConverter) resolve(uri string) (*url.URL, bool) {
+	uri = strings.TrimSpace(uri)
+	if uri == "" || strings.HasPrefix(uri, "#") {
+		return nil, false
+	}
+
+	base := mc.base
+	if base == nil {
+		base = mc.url
+	}
+	resolved, err := base.Parse(uri)
+	if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+		return nil, false
+	}
+	return resolved, true
+}
+
+// isAssetRel reports whether link element loads resource of page.
+func isAssetRel(n *html.Node) bool {
+	return hasRel(n, "stylesheet", "icon", "apple-touch-icon", "preload", "modulepreload", "manifest")
+}
+
+// hasRel reports whether rel attribute of element has one of values.
+func hasRel(n *html.Node, values ...string) bool {
+	for _, rel := range strings.Fields(strings.ToLower(attribute(n, "rel"))) {
+		for _, value := range values {
+			if rel == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rewriteSrcset replaces urls of image candidates, keeping descriptors.
+func rewriteSrcset(srcset string, fn func(uri string) string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = fn(fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// mirrorType returns media type of response, sniffed if it is not sent.
+func mirrorType(res *Response, body []byte) string {
+	contentType := res.Headers.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.ToLower(mediaType)
+}
+
+// mirrorPath returns slash separated path of url relative to mirror
+// directory.
+func mirrorPath(u *url.URL) string {
+	p := path.Clean("/" + u.Path)
+	if p == "/" || strings.HasSuffix(u.Path, "/") {
+		p = path.Join(p, "index.html")
+	}
+
+	dir, name := path.Split(p)
+	ext := path.Ext(name)
+	name = strings.TrimSuffix(name, ext)
+	if ext == "" {
+		ext = ".html"
+	}
+	if u.RawQuery != "" {
+		name += "@" + strings.ReplaceAll(u.RawQuery, "/", "%2F")
+	}
+
+	return path.Join(strings.ToLower(u.Host), dir, name+ext)
+}
+
+// relativePath returns link from file to other file of mirror.
+func relativePath(from string, to string, fragment string) string {
+	rel, err := filepath.Rel(filepath.Dir(filepath.FromSlash(from)), filepath.FromSlash(to))
+	if err != nil {
+		rel = to
+	}
+
+	link := &url.URL{Path: filepath.ToSlash(rel), Fragment: fragment}
+	return link.String()
+}
+
+// writeFile writes data to file, which becomes visible only once it is
+// complete.
+func writeFile(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package hopper
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMirror(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><head><link rel="stylesheet" href="/css/site.css?v=1"></head>`+
+			`<body><a href="/about">About</a><a href="/blocked">Blocked</a><a href="https://other.example/">Other</a>`+
+			`<img srcset="/img/logo.png 1x, /img/logo.png 2x"><div style="background: url(/img/bg.png)"></div></body></html>`)
+	})
+	mux.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><body><a href="/#top">Home</a></body></html>`)
+	})
+	mux.HandleFunc("/css/site.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		io.WriteString(w, `@import "base.css"; body { background: url('/img/bg.png') }`)
+	})
+	mux.HandleFunc("/css/base.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		io.WriteString(w, `body { margin: 0 }`)
+	})
+	mux.HandleFunc("/img/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, "\x89PNG")
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	crawler := Crawler{Client: srv.Client(), Scope: ScopeHost, Delay: TestDelay, Concurrency: 2}
+	crawler.Init()
+	mm := Mirror(&crawler, dir)
+	// Links to pages rejected after they were written stay absolute.
+	crawler.OnPush(func(r *Request) error {
+		if r.URL.Path == "/blocked" {
+			return ErrFiltered
+		}
+		return nil
+	})
+
+	crawler.Run(srv.URL + "/")
+	if err := mm.ConvertLinks(); err != nil {
+		t.Fatal(err)
+	}
+
+	host, _ := url.Parse(srv.URL)
+	tests := []struct {
+		Name string
+		Want []string
+	}{
+		{"index.html", []string{`href="css/site@v=1.css"`, `href="about.html"`, `href="` + srv.URL + `/blocked"`, `href="https://other.example/"`, `srcset="img/logo.png 1x, img/logo.png 2x"`, `url(img/bg.png)`}},
+		{"about.html", []string{`href="index.html#top"`}},
+		{"css/site@v=1.css", []string{`@import "base.css"`, `url('../img/bg.png')`}},
+		{"css/base.css", nil},
+		{"img/logo.png", nil},
+		{"img/bg.png", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join(dir, host.Host, filepath.FromSlash(test.Name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.Want {
+				if !strings.Contains(string(data), want) {
+					t.Fatalf("%s = %s, want it to contain %s", test.Name, data, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMirrorTruncated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><body><a href="/next">Next</a></body></html>`)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	crawler := Crawler{Client: srv.Client(), ContentLength: 16, AllowedDepth: 1, Delay: TestDelay}
+	crawler.Init()
+	Mirror(&crawler, dir)
+
+	var visitErr error
+	crawler.OnError(func(r *Request, err error) {
+		visitErr = err
+	})
+	crawler.Run(srv.URL + "/")
+
+	if !errors.Is(visitErr, ErrTruncatedBody) {
+		t.Fatalf("err = %v, want %v", visitErr, ErrTruncatedBody)
+	}
+	host, _ := url.Parse(srv.URL)
+	if _, err := os.Stat(filepath.Join(dir, host.Host, "index.html")); !os.IsNotExist(err) {
+		t.Fatalf("os.Stat(index.html) = %v, want it to not exist", err)
+	}
+}
+
+func TestMirrorPath(t *testing.T) {
+	tests := []struct {
+		URL  string
+		Want string
+	}{
+		{"http://a.com", "a.com/index.html"},
+		{"http://a.com/docs/", "a.com/docs/index.html"},
+		{"http://a.com/docs/page", "a.com/docs/page.html"},
+		{"http://a.com/style.css?v=1/2", "a.com/style@v=1%2F2.css"},
+		{"http://A.com:8080/../../etc/passwd", "a.com:8080/etc/passwd.html"},
+	}
+
+	for _, test := range tests {
+		u, _ := url.Parse(test.URL)
+		if got := mirrorPath(u); got != test.Want {
+			t.Fatalf("mirrorPath(%s) = %s, want %s", test.URL, got, test.Want)
+		}
+	}
+}
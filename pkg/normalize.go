@@ -0,0 +1,228 @@
+package hopper
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+type TrailingSlash int
+
+const (
+	// KeepTrailingSlash leaves paths as they were found.
+	KeepTrailingSlash TrailingSlash = iota
+	// AddTrailingSlash appends slash to paths whose last segment has no extension.
+	AddTrailingSlash
+	// RemoveTrailingSlash strips slash from every path except root.
+	RemoveTrailingSlash
+)
+
+var DefaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "msclkid", "mc_cid", "mc_eid", "_ga", "yclid",
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalizer canonicalises urls so that equivalent urls share one form.
+// Syntax based normalisations (case, default ports, dot-segments and
+// percent-encoding) are always applied, remaining ones are configurable.
+type Normalizer struct {
+	SortQuery     bool
+	StripParams   []string
+	TrailingSlash TrailingSlash
+	IDNA          bool
+}
+
+// DefaultNormalizer returns normalizer used by crawler when none is given.
+func DefaultNormalizer() *Normalizer {
+	return &Normalizer{
+		SortQuery:   true,
+		StripParams: DefaultTrackingParams,
+		IDNA:        true,
+	}
+}
+
+// Normalize modifies uri in place to its canonical form.
+func (n *Normalizer) Normalize(uri *url.URL) {
+	uri.Scheme = strings.ToLower(uri.Scheme)
+	uri.Fragment = ""
+	uri.RawFragment = ""
+
+	host := strings.ToLower(uri.Hostname())
+	if n.IDNA {
+		if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+			host = ascii
+		}
+	}
+	host = strings.TrimSuffix(host, ".")
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	port := uri.Port()
+	if port != "" && port != defaultPorts[uri.Scheme] {
+		host += ":" + port
+	}
+	uri.Host = host
+
+	escaped := normalizeEscapes(uri.EscapedPath())
+	escaped = removeDotSegments(escaped)
+	if escaped == "" && uri.Host != "" {
+		escaped = "/"
+	}
+	escaped = n.applyTrailingSlash(escaped)
+	if unescaped, err := url.PathUnescape(escaped); err == nil {
+		uri.Path = unescaped
+		uri.RawPath = escaped
+		if uri.EscapedPath() != escaped {
+			uri.RawPath = ""
+		}
+	}
+
+	uri.RawQuery = n.normalizeQuery(uri.RawQuery)
+	uri.ForceQuery = false
+}
+
+func (n *Normalizer) applyTrailingSlash(p string) string {
+	if p == "/" || p == "" {
+		return p
+	}
+
+	switch n.TrailingSlash {
+	case AddTrailingSlash:
+		if !strings.HasSuffix(p, "/") && path.Ext(p) == "" {
+			return p + "/"
+		}
+	case RemoveTrailingSlash:
+		return strings.TrimRight(p, "/")
+	}
+
+	return p
+}
+
+func (n *Normalizer) normalizeQuery(query string) string {
+	if query == "" {
+		return ""
+	}
+
+	params := []string{}
+	for _, param := range strings.Split(query, "&") {
+		if param == "" {
+			continue
+		}
+		param = normalizeEscapes(param)
+		key, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if n.stripped(key) {
+			continue
+		}
+		params = append(params, param)
+	}
+
+	if n.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			ki, _, _ := strings.Cut(params[i], "=")
+			kj, _, _ := strings.Cut(params[j], "=")
+			return ki < kj
+		})
+	}
+
+	return strings.Join(params, "&")
+}
+
+// stripped reports whether query key matches one of StripParams patterns.
+func (n *Normalizer) stripped(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range n.StripParams {
+		if matched, _ := path.Match(strings.ToLower(pattern), key); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters and
+// uppercases hex digits of the remaining escapes.
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+
+	return b.String()
+}
+
+// removeDotSegments implements algorithm from RFC 3986 section 5.2.4.
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+
+	out := []string{}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+
+	return strings.Join(out, "/")
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
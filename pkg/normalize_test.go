@@ -0,0 +1,63 @@
+package hopper
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		Name       string
+		Normalizer *Normalizer
+		URL        string
+		Want       string
+	}{
+		{"CaseAndPort", DefaultNormalizer(), "HTTP://Example.COM:80/a/../b?b=2&a=1", "http://example.com/b?a=1&b=2"},
+		{"HTTPSPort", DefaultNormalizer(), "https://example.com:443", "https://example.com/"},
+		{"CustomPort", DefaultNormalizer(), "http://example.com:8080/", "http://example.com:8080/"},
+		{"DotSegments", DefaultNormalizer(), "http://example.com/a/./b/../../c/", "http://example.com/c/"},
+		{"Escapes", DefaultNormalizer(), "http://example.com/%7euser/%2fx%3a", "http://example.com/~user/%2Fx%3A"},
+		{"TrackingParams", DefaultNormalizer(), "http://example.com/?utm_source=x&id=1&fbclid=2", "http://example.com/?id=1"},
+		{"Fragment", DefaultNormalizer(), "http://example.com/a#top", "http://example.com/a"},
+		{"IDNA", DefaultNormalizer(), "http://bücher.example/", "http://xn--bcher-kva.example/"},
+		{"KeepQueryOrder", &Normalizer{}, "http://example.com/?b=2&a=1", "http://example.com/?b=2&a=1"},
+		{"AddTrailingSlash", &Normalizer{TrailingSlash: AddTrailingSlash}, "http://example.com/a/b", "http://example.com/a/b/"},
+		{"AddTrailingSlashFile", &Normalizer{TrailingSlash: AddTrailingSlash}, "http://example.com/a/b.html", "http://example.com/a/b.html"},
+		{"RemoveTrailingSlash", &Normalizer{TrailingSlash: RemoveTrailingSlash}, "http://example.com/a/b/", "http://example.com/a/b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			uri, err := url.Parse(test.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.Normalizer.Normalize(uri)
+
+			if uri.String() != test.Want {
+				t.Fatalf("Normalize(%s) = %s, want %s", test.URL, uri.String(), test.Want)
+			}
+		})
+	}
+}
+
+func TestRequestNormalized(t *testing.T) {
+	parent := &Request{}
+	parent.Init()
+	parent.Properties["AllowedDepth"] = 1
+	parent.Properties["Normalizer"] = DefaultNormalizer()
+
+	a, err := parent.New("GET", "HTTP://Example.com:80/a/../b?b=2&a=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parent.New("GET", "http://example.com/b?a=1&b=2#x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.URL.String() != b.URL.String() {
+		t.Fatalf("Request.URL = %s, want %s", a.URL.String(), b.URL.String())
+	}
+}
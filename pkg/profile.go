@@ -0,0 +1,76 @@
+package hopper
+
+import (
+	"net/http"
+	"sync"
+)
+
+// HeaderProfile is set of headers sent together, as a browser would.
+// AcceptEncoding should only list encodings client can decode.
+type HeaderProfile struct {
+	UserAgent      string
+	Accept         string
+	AcceptLanguage string
+	AcceptEncoding string
+}
+
+// Header returns non empty headers of profile.
+func (hp HeaderProfile) Header() http.Header {
+	header := http.Header{}
+	for key, value := range map[string]string{
+		"User-Agent":      hp.UserAgent,
+		"Accept":          hp.Accept,
+		"Accept-Language": hp.AcceptLanguage,
+		"Accept-Encoding": hp.AcceptEncoding,
+	} {
+		if value != "" {
+			header.Set(key, value)
+		}
+	}
+	return header
+}
+
+// HeaderProfiles selects header profile for each request. Hosts pins
+// profile to host, other hosts get Profiles in rotation, or keep the
+// first one they got when Sticky is set.
+type HeaderProfiles struct {
+	sync.Mutex
+
+	Profiles []HeaderProfile
+	Hosts    map[string]HeaderProfile
+	Sticky   bool
+
+	next   int
+	sticky map[string]HeaderProfile
+}
+
+// Profile returns profile for request to host.
+func (hp *HeaderProfiles) Profile(host string) (HeaderProfile, bool) {
+	hp.Lock()
+	defer hp.Unlock()
+
+	if profile, exists := hp.Hosts[host]; exists {
+		return profile, true
+	}
+	if len(hp.Profiles) == 0 {
+		return HeaderProfile{}, false
+	}
+
+	if hp.Sticky {
+		if profile, exists := hp.sticky[host]; exists {
+			return profile, true
+		}
+	}
+
+	profile := hp.Profiles[hp.next%len(hp.Profiles)]
+	hp.next++
+
+	if hp.Sticky {
+		if hp.sticky == nil {
+			hp.sticky = map[string]HeaderProfile{}
+		}
+		hp.sticky[host] = profile
+	}
+
+	return profile, true
+}
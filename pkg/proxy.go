@@ -0,0 +1,232 @@
+package hopper
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultProxyMaxFailures = 3
+	DefaultProxyCooldown    = 5 * time.Minute
+)
+
+var ErrNoProxyAvailable = errors.New("No proxy available")
+
+type proxyContextKey struct{}
+
+type ProxyStrategy int
+
+const (
+	// RoundRobinProxy uses proxies one after another.
+	RoundRobinProxy ProxyStrategy = iota
+	// RandomProxy picks random proxy for each request.
+	RandomProxy
+	// StickyProxy keeps using the same proxy for each host.
+	StickyProxy
+)
+
+type proxyState struct {
+	uri      *url.URL
+	failures int
+	evicted  time.Time
+}
+
+// ProxyPool selects proxy for each request. HTTP, HTTPS and SOCKS5
+// proxies are supported. Proxy failing MaxFailures times in a row is
+// evicted for Cooldown.
+type ProxyPool struct {
+	sync.Mutex
+
+	Proxies     []*url.URL
+	Strategy    ProxyStrategy
+	MaxFailures int
+	Cooldown    time.Duration
+
+	states []*proxyState
+	next   int
+	sticky map[string]*proxyState
+}
+
+func (pp *ProxyPool) init() {
+	if pp.MaxFailures == 0 {
+		pp.MaxFailures = DefaultProxyMaxFailures
+	}
+	if pp.Cooldown == 0 {
+		pp.Cooldown = DefaultProxyCooldown
+	}
+
+	pp.states = make([]*proxyState, len(pp.Proxies))
+	for i, proxy := range pp.Proxies {
+		pp.states[i] = &proxyState{uri: proxy}
+	}
+	pp.sticky = map[string]*proxyState{}
+}
+
+// Proxy returns proxy for request to host.
+func (pp *ProxyPool) Proxy(host string) (*url.URL, error) {
+	pp.Lock()
+	defer pp.Unlock()
+
+	if pp.states == nil {
+		pp.init()
+	}
+
+	now := time.Now()
+	available := []*proxyState{}
+	for _, state := range pp.states {
+		if !state.evicted.After(now) {
+			available = append(available, state)
+		}
+	}
+	if len(available) == 0 {
+		return nil, ErrNoProxyAvailable
+	}
+
+	switch pp.Strategy {
+	case RandomProxy:
+		return available[rand.Intn(len(available))].uri, nil
+	case StickyProxy:
+		if state, exists := pp.sticky[host]; exists && !state.evicted.After(now) {
+			return state.uri, nil
+		}
+		state := available[rand.Intn(len(available))]
+		pp.sticky[host] = state
+		return state.uri, nil
+	default:
+		for i := range pp.states {
+			state := pp.states[(pp.next+i)%len(pp.states)]
+			if !state.evicted.After(now) {
+				pp.next += i + 1
+				return state.uri, nil
+			}
+		}
+		return nil, ErrNoProxyAvailable
+	}
+}
+
+// Report records result of request made through proxy.
+func (pp *ProxyPool) Report(proxy *url.URL, err error) {
+	pp.Lock()
+	defer pp.Unlock()
+
+	for _, state := range pp.states {
+		if state.uri != proxy {
+			continue
+		}
+
+		if err == nil {
+			state.failures = 0
+			return
+		}
+
+		state.failures++
+		if state.failures >= pp.MaxFailures {
+			state.evicted = time.Now().Add(pp.Cooldown)
+			state.failures = 0
+		}
+		return
+	}
+}
+
+// Check sends request to target through every proxy and reports result,
+// so that dead proxies are evicted before crawl uses them.
+func (pp *ProxyPool) Check(target string, timeout time.Duration) {
+	var wg sync.WaitGroup
+	for _, proxy := range pp.Proxies {
+		wg.Add(1)
+		go func(proxy *url.URL) {
+			defer wg.Done()
+
+			client := &http.Client{
+				Timeout:   timeout,
+				Transport: &http.Transport{Proxy: http.ProxyURL(proxy)},
+			}
+			res, err := client.Get(target)
+			if err == nil {
+				res.Body.Close()
+				if res.StatusCode == http.StatusProxyAuthRequired || res.StatusCode >= 500 {
+					err = errors.New(res.Status)
+				}
+			}
+
+			for i := 0; i < pp.maxFailures(); i++ {
+				pp.Report(proxy, err)
+				if err == nil {
+					break
+				}
+			}
+		}(proxy)
+	}
+	wg.Wait()
+}
+
+func (pp *ProxyPool) maxFailures() int {
+	pp.Lock()
+	defer pp.Unlock()
+
+	if pp.states == nil {
+		pp.init()
+	}
+	return pp.MaxFailures
+}
+
+// ProxyTransport routes requests through proxy given in request context,
+// or selected from Pool. Proxy of underlying transport is used otherwise.
+type ProxyTransport struct {
+	Transport *http.Transport
+	Pool      *ProxyPool
+
+	once sync.Once
+}
+
+func (t *ProxyTransport) init() {
+	if t.Transport == nil {
+		t.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	fallback := t.Transport.Proxy
+	t.Transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxy, ok := req.Context().Value(proxyContextKey{}).(*url.URL); ok {
+			return proxy, nil
+		}
+		if fallback != nil {
+			return fallback(req)
+		}
+		return nil, nil
+	}
+}
+
+func (t *ProxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.once.Do(t.init)
+
+	if _, ok := req.Context().Value(proxyContextKey{}).(*url.URL); ok || t.Pool == nil {
+		return t.Transport.RoundTrip(req)
+	}
+
+	proxy, err := t.Pool.Proxy(req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.Transport.RoundTrip(req.WithContext(context.WithValue(req.Context(), proxyContextKey{}, proxy)))
+	if err == nil && res.StatusCode == http.StatusProxyAuthRequired {
+		t.Pool.Report(proxy, errors.New(res.Status))
+	} else {
+		t.Pool.Report(proxy, err)
+	}
+
+	return res, err
+}
+
+// withProxy returns context which makes ProxyTransport use proxy.
+func withProxy(ctx context.Context, proxy *url.URL) context.Context {
+	if proxy == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, proxyContextKey{}, proxy)
+}
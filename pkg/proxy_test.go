@@ -0,0 +1,72 @@
+package hopper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProxyPool(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	proxy := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[name]++
+			mu.Unlock()
+		}))
+	}
+
+	first := proxy("first")
+	defer first.Close()
+	second := proxy("second")
+	defer second.Close()
+	dead := httptest.NewServer(nil)
+	dead.Close()
+
+	parse := func(s string) *url.URL {
+		u, _ := url.Parse(s)
+		return u
+	}
+	pool := &ProxyPool{
+		Proxies:     []*url.URL{parse(first.URL), parse(second.URL), parse(dead.URL)},
+		MaxFailures: 1,
+		Cooldown:    time.Hour,
+	}
+
+	client := &Client{Client: &http.Client{}, Proxies: pool}
+	client.Init()
+
+	uri := parse("http://example.com/")
+	failures := 0
+	for i := 0; i < 6; i++ {
+		res, err := client.Do(http.MethodGet, uri, nil, http.Header{})
+		if err != nil {
+			failures++
+			continue
+		}
+		res.Body.Close()
+	}
+
+	if failures != 1 || hits["first"] != 3 || hits["second"] != 2 {
+		t.Fatalf("failures, hits = %d, %v, want 1, map[first:3 second:2]", failures, hits)
+	}
+
+	req := &Request{}
+	req.Init()
+	req.Method = http.MethodGet
+	req.URL = uri
+	req.Proxy = parse(second.URL)
+	res, err := client.DoRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if hits["second"] != 3 {
+		t.Fatalf("hits[second] = %d, want 3", hits["second"])
+	}
+}
@@ -8,18 +8,29 @@ import (
 	"time"
 )
 
+// popPollInterval limits how long Pop waits before looking at queue again,
+// so that newly pushed hosts which are due sooner are not missed.
+const popPollInterval = 100 * time.Millisecond
+
 type PQueueItem struct {
 	value    any
 	priority int
+	score    float64
 	index    int
+	// heap is PQueue item is in, if any.
+	heap *PQueue
 }
 
+// PQueue is max-heap ordered by priority and then by score.
 type PQueue []*PQueueItem
 
 func (pq PQueue) Len() int { return len(pq) }
 
 func (pq PQueue) Less(i, j int) bool {
-	return pq[i].priority > pq[j].priority
+	if pq[i].priority != pq[j].priority {
+		return pq[i].priority > pq[j].priority
+	}
+	return pq[i].score > pq[j].score
 }
 
 func (pq PQueue) Swap(i, j int) {
@@ -43,55 +54,123 @@ func (pq *PQueue) Pop() any {
 	return item
 }
 
-func (pq *PQueue) Update(item *PQueueItem, value any, priority int) {
+func (pq *PQueue) Update(item *PQueueItem, value any, priority int, score float64) {
 	item.value = value
 	item.priority = priority
+	item.score = score
 	heap.Fix(pq, item.index)
 }
 
+// Peek returns item with highest priority without removing it.
 func (pq *PQueue) Peek() any {
-	q := *pq
-	n := len(q)
-	return q[n-1]
+	return (*pq)[0]
+}
+
+type delayedItem struct {
+	req *Request
+	seq int
 }
 
+// requestHeap orders requests by their NotBefore time, then by Priority,
+// FIFO for equal priorities.
+type requestHeap []delayedItem
+
+func (rh requestHeap) Len() int { return len(rh) }
+
+func (rh requestHeap) Less(i, j int) bool {
+	if !rh[i].req.NotBefore.Equal(rh[j].req.NotBefore) {
+		return rh[i].req.NotBefore.Before(rh[j].req.NotBefore)
+	}
+	if rh[i].req.Priority != rh[j].req.Priority {
+		return rh[i].req.Priority > rh[j].req.Priority
+	}
+	return rh[i].seq < rh[j].seq
+}
+
+func (rh requestHeap) Swap(i, j int) { rh[i], rh[j] = rh[j], rh[i] }
+
+func (rh *requestHeap) Push(x any) { *rh = append(*rh, x.(delayedItem)) }
+
+func (rh *requestHeap) Pop() any {
+	old := *rh
+	n := len(old)
+	item := old[n-1]
+	*rh = old[:n-1]
+	return item
+}
+
+// DelayedQueue holds requests of single host and serves them in order of
+// their priority, no sooner than delay after previous request.
 type DelayedQueue struct {
 	clock time.Time
-	queue []*Request
+	queue requestHeap
+	seq   int
 }
 
-// Pop sleeps until it can returns url from HostQueue.
-func (h *DelayedQueue) Pop() *Request {
+// Due returns time at which next request can be sent.
+func (h *DelayedQueue) Due() time.Time {
+	req := h.queue[0].req
+	due := h.clock.Add(req.Properties["Delay"].(time.Duration))
+	if req.NotBefore.After(due) {
+		return req.NotBefore
+	}
+	return due
+}
 
-	req := h.queue[len(h.queue)-1]
-	h.queue = h.queue[:len(h.queue)-1]
-    
-    time.Sleep(time.Until(h.clock.Add(req.Properties["Delay"].(time.Duration))))
+// Score returns priority of the next request.
+func (h *DelayedQueue) Score() float64 {
+	return h.queue[0].req.Priority
+}
+
+// Pop returns request with highest priority and resets delay clock.
+func (h *DelayedQueue) Pop() *Request {
+	item := heap.Pop(&h.queue).(delayedItem)
 	h.clock = time.Now()
 
-	return req
+	return item.req
+}
+
+// Raise restores order of queued request after its priority was raised.
+func (h *DelayedQueue) Raise(req *Request) {
+	for i, item := range h.queue {
+		if item.req == req {
+			heap.Fix(&h.queue, i)
+			return
+		}
+	}
 }
 
 // Push adds new url to HostQueue.
 func (h *DelayedQueue) Push(req *Request) {
-	h.queue = append(h.queue, req)
+	heap.Push(&h.queue, delayedItem{req: req, seq: h.seq})
+	h.seq++
 }
 
 func (h *DelayedQueue) Len() int {
 	return len(h.queue)
 }
 
+// URLQueue is crawl frontier. Hosts which are due are served in order of
+// score of their best request, others wait for their due time.
 type URLQueue struct {
 	sync.Mutex
 
 	Free chan int
 	Max  int
+	Seen SeenSet
     
     running bool
 	threads int
+	// active counts popped requests which are still being visited and
+	// pending seeds, they may push more requests.
+	active int
+	// Hosts wait in queue until their due time and are served from
+	// ready by score.
 	queue   *PQueue
+	ready   *PQueue
 	itemMap map[string]*PQueueItem
-	seen    map[string]bool
+	// pending are queued requests by their key.
+	pending map[string]*Request
 }
 
 func (u *URLQueue) Init() {
@@ -99,27 +178,40 @@ func (u *URLQueue) Init() {
 		u.Max = runtime.GOMAXPROCS(0)
 	}
 	u.queue = &PQueue{}
+	u.ready = &PQueue{}
 	u.itemMap = map[string]*PQueueItem{}
-	u.seen = map[string]bool{}
+	u.pending = map[string]*Request{}
+	if u.Seen == nil {
+		u.Seen = &MapSeenSet{}
+	}
 	u.Free = make(chan int)
     u.running = true
 
 	heap.Init(u.queue)
+	heap.Init(u.ready)
 }
 
-// Pushes requests to HostQueues and creates them if necessary.
+// Pushes requests to HostQueues and creates them if necessary. Already
+// seen request raises priority of its queued one, if it scored higher.
 // It also sends to channel when it should create new threads.
 func (u *URLQueue) Push(req *Request) {
 	u.Lock()
 	defer u.Unlock()
 
-	if !u.seen[req.URL.String()] {
-		hq := u.getHostQueue(req)
-		hq.Push(req)
-		u.seen[req.URL.String()] = true
+	if req.Recrawl || u.Seen.Add(req.Key()) {
+		item := u.getHostItem(req)
+		item.value.(*DelayedQueue).Push(req)
+		u.fix(item)
+		u.pending[req.Key()] = req
+	} else if queued, exists := u.pending[req.Key()]; exists && req.Priority > queued.Priority {
+		// Duplicate which scored higher raises queued request.
+		queued.Priority = req.Priority
+		item := u.getHostItem(queued)
+		item.value.(*DelayedQueue).Raise(queued)
+		u.fix(item)
 	}
 
-	balance := u.queue.Len() - u.threads
+	balance := u.len() - u.threads
 	if balance > 0 && u.threads < u.Max {
 		free := int(math.Min(float64(balance), float64(u.Max-u.threads)))
 		u.threads += free
@@ -127,49 +219,104 @@ func (u *URLQueue) Push(req *Request) {
 	}
 }
 
-// Pop returns url from most prioritorized HostQueue and updates heap tree.
-func (u *URLQueue) Pop() *Request {
+// See marks request as seen without queueing it.
+func (u *URLQueue) See(req *Request) {
 	u.Lock()
 	defer u.Unlock()
 
-	item := u.queue.Peek().(*PQueueItem)
-	req := item.value.(*DelayedQueue).Pop()
+	u.Seen.Add(req.Key())
+}
 
-	if item.value.(*DelayedQueue).Len() == 0 {
-		heap.Remove(u.queue, item.index)
-	} else {
-		u.queue.Update(item, item.value, int(item.value.(*DelayedQueue).clock.Unix()))
+// Pop returns request from due HostQueue with best score and updates heap
+// trees. It waits without holding lock until some host is due and returns
+// nil when queue becomes empty in the meantime.
+func (u *URLQueue) Pop() *Request {
+	for {
+		u.Lock()
+		if u.len() == 0 {
+			u.Unlock()
+			return nil
+		}
+
+		// Due time only decides when host becomes ready.
+		now := time.Now()
+		for u.queue.Len() > 0 {
+			item := u.queue.Peek().(*PQueueItem)
+			if item.value.(*DelayedQueue).Due().After(now) {
+				break
+			}
+			u.fix(item)
+		}
+
+		if u.ready.Len() == 0 {
+			wait := time.Until(u.queue.Peek().(*PQueueItem).value.(*DelayedQueue).Due())
+			u.Unlock()
+			time.Sleep(time.Duration(math.Min(float64(wait), float64(popPollInterval))))
+			continue
+		}
+
+		item := u.ready.Peek().(*PQueueItem)
+		hq := item.value.(*DelayedQueue)
+		req := hq.Pop()
+		if u.pending[req.Key()] == req {
+			delete(u.pending, req.Key())
+		}
+		if hq.Len() == 0 {
+			heap.Remove(item.heap, item.index)
+			item.heap = nil
+		} else {
+			u.fix(item)
+		}
+
+		u.active++
+		u.Unlock()
+
+		return req
 	}
+}
 
-	balance := u.queue.Len() - u.threads
-	if balance < 0 && u.threads <= u.Max {
-		u.threads += balance
-	}
+// fix moves host item to heap it belongs to after its queue has changed.
+// Waiting hosts are ordered by due time, ready ones by score.
+func (u *URLQueue) fix(item *PQueueItem) {
+	hq := item.value.(*DelayedQueue)
+	due := hq.Due()
 
-	return req
+	target, priority := u.ready, 0
+	if due.After(time.Now()) {
+		target, priority = u.queue, -int(due.UnixMilli())
+	}
+	if item.heap != target {
+		if item.heap != nil {
+			heap.Remove(item.heap, item.index)
+		}
+		item.heap = target
+		heap.Push(target, item)
+	}
+	target.Update(item, hq, priority, hq.Score())
 }
 
-// getHostQueue returns DelayedQueue for equivalent hostname and creates it,
-// if one does not exists.
-func (u *URLQueue) getHostQueue(req *Request) *DelayedQueue {
+// getHostItem returns heap item of DelayedQueue for equivalent hostname
+// and creates it, if one does not exists.
+func (u *URLQueue) getHostItem(req *Request) *PQueueItem {
 	item, exists := u.itemMap[req.URL.Hostname()]
 	if !exists {
-		queue := &DelayedQueue{queue: []*Request{}}
-		item = &PQueueItem{value: queue, priority: int(queue.clock.Unix())}
+		item = &PQueueItem{value: &DelayedQueue{}}
 		u.itemMap[req.URL.Hostname()] = item
-		heap.Push(u.queue, item)
-	} else if item.value.(*DelayedQueue).Len() == 0 {
-		heap.Push(u.queue, item)
 	}
 
-	return item.value.(*DelayedQueue)
+	return item
 }
 
 func (u *URLQueue) Len() int {
 	u.Lock()
 	defer u.Unlock()
 
-	return u.queue.Len()
+	return u.len()
+}
+
+// len returns number of hosts with queued requests.
+func (u *URLQueue) len() int {
+	return u.queue.Len() + u.ready.Len()
 }
 
 func (u *URLQueue) Threads() int {
@@ -179,6 +326,46 @@ func (u *URLQueue) Threads() int {
 	return u.threads
 }
 
+// Begin marks work which may push requests, so that queue is not closed
+// before it is Done.
+func (u *URLQueue) Begin() {
+	u.Lock()
+	defer u.Unlock()
+
+	u.active++
+}
+
+// Done marks popped request or work started by Begin as finished.
+func (u *URLQueue) Done() {
+	u.Lock()
+	defer u.Unlock()
+
+	u.active--
+	u.closeIdle()
+}
+
+// Leave reports whether worker can exit, which is when queue is empty.
+// Last worker closes queue once nothing can push to it anymore.
+func (u *URLQueue) Leave() bool {
+	u.Lock()
+	defer u.Unlock()
+
+	if u.len() > 0 {
+		return false
+	}
+
+	u.threads--
+	u.closeIdle()
+	return true
+}
+
+func (u *URLQueue) closeIdle() {
+	if u.running && u.threads == 0 && u.active == 0 && u.len() == 0 {
+		close(u.Free)
+		u.running = false
+	}
+}
+
 func (u *URLQueue) Close() {
     u.Lock()
     defer u.Unlock()
@@ -0,0 +1,164 @@
+package hopper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestURLQueuePriority(t *testing.T) {
+	seed := &Request{}
+	seed.Init()
+	seed.Properties["AllowedDepth"] = 10
+	seed.Properties["Delay"] = time.Millisecond
+
+	tests := []struct {
+		Name   string
+		Scorer Scorer
+		Want   []string
+	}{
+		{"BFS", BFSScorer, []string{"/", "/a", "/b", "/a/1", "/a/2"}},
+		{"DFS", DFSScorer, []string{"/a/1", "/a/2", "/a", "/b", "/"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			queue := &URLQueue{Max: 1}
+			queue.Init()
+			go func() {
+				for range queue.Free {
+				}
+			}()
+			defer queue.Close()
+
+			root, _ := seed.New("GET", "http://a.com/")
+			a, _ := root.New("GET", "/a")
+			b, _ := root.New("GET", "/b")
+			a1, _ := a.New("GET", "/a/1")
+			a2, _ := a.New("GET", "/a/2")
+
+			for _, req := range []*Request{root, a, a1, b, a2} {
+				req.Priority = test.Scorer(req)
+				queue.Push(req)
+			}
+
+			for i, want := range test.Want {
+				req := queue.Pop()
+				if req.URL.Path != want {
+					t.Fatalf("queue.Pop() #%d = %s, want %s", i, req.URL.Path, want)
+				}
+			}
+		})
+	}
+}
+
+func TestURLQueueHosts(t *testing.T) {
+	seed := &Request{}
+	seed.Init()
+	seed.Properties["AllowedDepth"] = 10
+	seed.Properties["Delay"] = 20 * time.Millisecond
+
+	queue := &URLQueue{Max: 1}
+	queue.Init()
+	go func() {
+		for range queue.Free {
+		}
+	}()
+	defer queue.Close()
+
+	push := func(uri string, score float64) {
+		req, _ := seed.New("GET", uri)
+		req.Priority = score
+		queue.Push(req)
+	}
+	push("http://a.com/low", 1)
+	push("http://b.com/best", 5)
+	push("http://b.com/good", 4)
+
+	// b.com is due later than a.com after first request, but once both
+	// are due its better request goes first.
+	want := []string{"http://b.com/best", "http://b.com/good", "http://a.com/low"}
+	for i, uri := range want {
+		if req := queue.Pop(); req.URL.String() != uri {
+			t.Fatalf("queue.Pop() #%d = %s, want %s", i, req.URL, uri)
+		}
+		if i == 0 {
+			time.Sleep(30 * time.Millisecond)
+		}
+	}
+}
+
+func TestURLQueueRescore(t *testing.T) {
+	seed := &Request{}
+	seed.Init()
+	seed.Properties["AllowedDepth"] = 10
+	seed.Properties["Delay"] = time.Millisecond
+
+	queue := &URLQueue{Max: 1}
+	queue.Init()
+	go func() {
+		for range queue.Free {
+		}
+	}()
+	defer queue.Close()
+
+	scorer := InLinkScorer(1)
+	for _, uri := range []string{"/", "/once", "/twice", "/twice"} {
+		req, _ := seed.New("GET", "http://a.com"+uri)
+		req.Priority = scorer(req)
+		queue.Push(req)
+	}
+
+	for i, want := range []string{"/twice", "/", "/once"} {
+		if req := queue.Pop(); req.URL.Path != want {
+			t.Fatalf("queue.Pop() #%d = %s, want %s", i, req.URL.Path, want)
+		}
+	}
+}
+
+func TestURLQueueClose(t *testing.T) {
+	seed := &Request{}
+	seed.Init()
+	seed.Properties["AllowedDepth"] = 10
+	seed.Properties["Delay"] = time.Millisecond
+
+	queue := &URLQueue{Max: 1}
+	queue.Init()
+	closed := make(chan struct{})
+	go func() {
+		for range queue.Free {
+		}
+		close(closed)
+	}()
+	isClosed := func() bool {
+		select {
+		case <-closed:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}
+
+	root, _ := seed.New("GET", "http://a.com/")
+	queue.Begin()
+	queue.Push(root)
+	queue.Done()
+
+	req := queue.Pop()
+	// Worker leaves while request it popped may still push links.
+	if !queue.Leave() || isClosed() {
+		t.Fatalf("queue closed while %s is visited", req.URL)
+	}
+
+	child, _ := req.New("GET", "/a")
+	queue.Push(child)
+	queue.Done()
+	if queue.Leave() || isClosed() {
+		t.Fatalf("queue closed with %s pending", child.URL)
+	}
+
+	queue.Pop()
+	queue.Done()
+	if !queue.Leave() || !isClosed() {
+		t.Fatalf("queue not closed after last request was visited")
+	}
+}
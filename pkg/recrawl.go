@@ -0,0 +1,274 @@
+package hopper
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMinRecrawlInterval = time.Hour
+	DefaultMaxRecrawlInterval = 30 * 24 * time.Hour
+)
+
+type Change int
+
+const (
+	// ChangeNew is reported on first fetch of url.
+	ChangeNew Change = iota
+	ChangeUnchanged
+	ChangeChanged
+	// ChangeGone is reported when revisited url responds with 404 or 410.
+	ChangeGone
+)
+
+func (c Change) String() string {
+	switch c {
+	case ChangeUnchanged:
+		return "unchanged"
+	case ChangeChanged:
+		return "changed"
+	case ChangeGone:
+		return "gone"
+	default:
+		return "new"
+	}
+}
+
+// PageState is what recrawl scheduler remembers about fetched url.
+type PageState struct {
+	LastFetch    time.Time
+	ETag         string
+	LastModified string
+	Hash         [sha256.Size]byte
+
+	// Revisits and Changes count how many times page was revisited and how
+	// many of these revisits found it changed over Elapsed time.
+	Revisits int
+	Changes  int
+	Elapsed  time.Duration
+	Interval time.Duration
+	// Failures counts consecutive revisits which failed.
+	Failures int
+}
+
+// ChangeRate estimates Poisson rate of changes per second from incomplete
+// change history, as proposed by Cho and Garcia-Molina.
+func (ps *PageState) ChangeRate() float64 {
+	if ps.Revisits == 0 || ps.Elapsed <= 0 {
+		return 0
+	}
+
+	n, x := float64(ps.Revisits), float64(ps.Changes)
+	interval := ps.Elapsed.Seconds() / n
+
+	return -math.Log((n-x+0.5)/(n+0.5)) / interval
+}
+
+type RecrawlMiddleware struct {
+	sync.Mutex
+
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	crawler *Crawler
+	states  map[string]*PageState
+}
+
+// Recrawl keeps crawled pages fresh. After each response it compares page
+// with its previous fetch, sets Response.Change for following handlers and
+// pushes request again to be revisited after interval adapted to page change
+// rate. It should be registered before handlers interested in changes.
+// Revisits failing on server or network errors are retried with backoff.
+// Crawler with recrawl never runs out of requests while pages exist.
+func Recrawl(crawler *Crawler) *RecrawlMiddleware {
+	rm := &RecrawlMiddleware{
+		MinInterval: DefaultMinRecrawlInterval,
+		MaxInterval: DefaultMaxRecrawlInterval,
+		crawler:     crawler,
+		states:      map[string]*PageState{},
+	}
+
+	crawler.OnResponse(func(r *Response) error {
+		change, err := rm.Update(r)
+		if err != nil {
+			return fmt.Errorf("Recrawl: %w", err)
+		}
+		r.Change = change
+
+		if change == ChangeGone {
+			r.NoFollow = true
+			return nil
+		}
+
+		return rm.Schedule(r.Request)
+	})
+
+	crawler.OnError(func(r *Request, err error) {
+		if r.Recrawl && retryable(err) {
+			rm.Retry(r)
+		}
+	})
+
+	return rm
+}
+
+// retryable reports whether error is one of server or network, which
+// may go away.
+func retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= 500 || status.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr *url.Error
+	return errors.As(err, &netErr)
+}
+
+// Update records fetch of response and reports how page changed.
+func (rm *RecrawlMiddleware) Update(r *Response) (Change, error) {
+	key := r.Request.URL.String()
+	now := time.Now()
+
+	if r.StatusCode == http.StatusNotFound || r.StatusCode == http.StatusGone {
+		rm.Lock()
+		delete(rm.states, key)
+		rm.Unlock()
+		return ChangeGone, nil
+	}
+
+	rm.Lock()
+	state, exists := rm.states[key]
+	// Not modified page has no body to compare.
+	if exists && r.NotModified {
+		state.Failures = 0
+		state.Revisits++
+		state.Elapsed += now.Sub(state.LastFetch)
+		state.LastFetch = now
+		state.Interval = rm.interval(state)
+		rm.Unlock()
+		return ChangeUnchanged, nil
+	}
+	rm.Unlock()
+
+	body, err := r.Bytes()
+	if err != nil {
+		return ChangeNew, err
+	}
+	fingerprint := r.Fingerprint
+	if fingerprint == nil {
+		fingerprint = NewFingerprint(body)
+	}
+
+	rm.Lock()
+	defer rm.Unlock()
+
+	state, exists = rm.states[key]
+	if !exists {
+		rm.states[key] = &PageState{
+			LastFetch:    now,
+			ETag:         r.Headers.Get("ETag"),
+			LastModified: r.Headers.Get("Last-Modified"),
+			Hash:         fingerprint.Exact,
+			Interval:     rm.MinInterval,
+		}
+		return ChangeNew, nil
+	}
+
+	change := ChangeUnchanged
+	if state.Hash != fingerprint.Exact {
+		change = ChangeChanged
+		state.Changes++
+	}
+	state.Failures = 0
+	state.Revisits++
+	state.Elapsed += now.Sub(state.LastFetch)
+	state.LastFetch = now
+	state.Hash = fingerprint.Exact
+	state.ETag = r.Headers.Get("ETag")
+	state.LastModified = r.Headers.Get("Last-Modified")
+	state.Interval = rm.interval(state)
+
+	return change, nil
+}
+
+// Schedule pushes copy of request to be revisited after its interval.
+func (rm *RecrawlMiddleware) Schedule(req *Request) error {
+	rm.Lock()
+	state, exists := rm.states[req.URL.String()]
+	if !exists {
+		rm.Unlock()
+		return nil
+	}
+	interval := state.Interval
+	rm.Unlock()
+
+	return rm.push(req, interval)
+}
+
+// Retry pushes copy of failed revisit again after backoff, which starts at
+// MinInterval and doubles with each consecutive failure up to MaxInterval.
+// Page stays known until it responds with 404 or 410.
+func (rm *RecrawlMiddleware) Retry(req *Request) error {
+	rm.Lock()
+	state, exists := rm.states[req.URL.String()]
+	if !exists {
+		rm.Unlock()
+		return nil
+	}
+	state.Failures++
+	backoff := rm.MaxInterval
+	if state.Failures < 32 && rm.MinInterval<<(state.Failures-1) < rm.MaxInterval {
+		backoff = rm.MinInterval << (state.Failures - 1)
+	}
+	rm.Unlock()
+
+	return rm.push(req, backoff)
+}
+
+// push pushes copy of request to be revisited after interval.
+func (rm *RecrawlMiddleware) push(req *Request, interval time.Duration) error {
+	revisit := *req
+	revisit.Headers = http.Header{}
+	revisit.Properties = map[string]any{}
+	for k, v := range req.Properties {
+		revisit.Properties[k] = v
+	}
+	revisit.Recrawl = true
+	revisit.NotBefore = time.Now().Add(interval)
+
+	return rm.crawler.Push(&revisit)
+}
+
+// State returns copy of remembered state of url.
+func (rm *RecrawlMiddleware) State(uri string) (PageState, bool) {
+	rm.Lock()
+	defer rm.Unlock()
+
+	state, exists := rm.states[uri]
+	if !exists {
+		return PageState{}, false
+	}
+	return *state, true
+}
+
+// interval returns expected time between changes bounded by Min and
+// MaxInterval. Until first change is seen interval is doubled instead.
+func (rm *RecrawlMiddleware) interval(state *PageState) time.Duration {
+	interval := 2 * state.Interval
+	if rate := state.ChangeRate(); rate > 0 {
+		interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	if interval < rm.MinInterval {
+		return rm.MinInterval
+	}
+	if interval > rm.MaxInterval {
+		return rm.MaxInterval
+	}
+	return interval
+}
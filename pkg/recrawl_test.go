@@ -0,0 +1,147 @@
+package hopper
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecrawlUpdate(t *testing.T) {
+	crawler := Crawler{}
+	crawler.Init()
+	rm := Recrawl(&crawler)
+
+	root := crawler.Root()
+	req, _ := root.New(http.MethodGet, "http://example.com/page")
+	respond := func(status int, body string) *Response {
+		httpRes := &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}
+		res, err := NewResponse(httpRes, req.Properties, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	tests := []struct {
+		Name   string
+		Status int
+		Body   string
+		Want   Change
+	}{
+		{"New", http.StatusOK, "<p>first</p>", ChangeNew},
+		{"NotModified", http.StatusNotModified, "", ChangeUnchanged},
+		{"Unchanged", http.StatusOK, "<p>first</p>", ChangeUnchanged},
+		{"Changed", http.StatusOK, "<p>second</p>", ChangeChanged},
+	}
+
+	for _, test := range tests {
+		change, err := rm.Update(respond(test.Status, test.Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if change != test.Want {
+			t.Fatalf("%s: rm.Update = %s, want %s", test.Name, change, test.Want)
+		}
+		// Revisits are apart in time.
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRecrawlSchedule(t *testing.T) {
+	crawler := Crawler{}
+	crawler.Init()
+	rm := Recrawl(&crawler)
+	go func() {
+		for range crawler.queue.Free {
+		}
+	}()
+	defer crawler.queue.Close()
+
+	var revisit *Request
+	crawler.OnPush(func(r *Request) error {
+		revisit = r
+		return nil
+	})
+
+	root := crawler.Root()
+	req, _ := root.New(http.MethodGet, "http://example.com/page")
+	httpRes := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("<p>page</p>"))}
+	res, _ := NewResponse(httpRes, req.Properties, req)
+	if _, err := rm.Update(res); err != nil {
+		t.Fatal(err)
+	}
+	if err := rm.Schedule(req); err != nil {
+		t.Fatal(err)
+	}
+
+	revisit.Properties["Checksum"] = "changed"
+	if !revisit.Recrawl || revisit.NotBefore.Before(time.Now().Add(rm.MinInterval/2)) {
+		t.Fatalf("revisit = %t at %v, want recrawl after interval", revisit.Recrawl, revisit.NotBefore)
+	}
+	if _, shared := req.Properties["Checksum"]; shared {
+		t.Fatalf("request Properties = %v, want them not shared with revisit", req.Properties)
+	}
+}
+
+func TestRecrawlRetry(t *testing.T) {
+	crawler := Crawler{}
+	crawler.Init()
+	rm := Recrawl(&crawler)
+	go func() {
+		for range crawler.queue.Free {
+		}
+	}()
+	defer crawler.queue.Close()
+
+	var revisit *Request
+	crawler.OnPush(func(r *Request) error {
+		revisit = r
+		return nil
+	})
+
+	root := crawler.Root()
+	req, _ := root.New(http.MethodGet, "http://example.com/page")
+	httpRes := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("<p>page</p>"))}
+	res, _ := NewResponse(httpRes, req.Properties, req)
+	if _, err := rm.Update(res); err != nil {
+		t.Fatal(err)
+	}
+	req.Recrawl = true
+
+	tests := []struct {
+		Name    string
+		Err     error
+		Backoff time.Duration
+	}{
+		{"Filtered", ErrFiltered, 0},
+		{"ServerError", fmt.Errorf("Request: %w", &StatusError{StatusCode: http.StatusServiceUnavailable}), rm.MinInterval},
+		{"NetworkError", fmt.Errorf("Request: %w", &url.Error{Op: "Get", URL: req.URL.String(), Err: io.ErrUnexpectedEOF}), 2 * rm.MinInterval},
+		{"ClientError", fmt.Errorf("Request: %w", &StatusError{StatusCode: http.StatusForbidden}), 0},
+	}
+
+	for _, test := range tests {
+		revisit = nil
+		start := time.Now()
+		for _, fn := range crawler.onError {
+			fn(req, test.Err)
+		}
+
+		if test.Backoff == 0 {
+			if revisit != nil {
+				t.Fatalf("%s: revisit at %v, want none", test.Name, revisit.NotBefore)
+			}
+			continue
+		}
+		if revisit == nil || revisit.NotBefore.Before(start.Add(test.Backoff)) || revisit.NotBefore.After(time.Now().Add(test.Backoff)) {
+			t.Fatalf("%s: revisit = %v, want one after %v", test.Name, revisit, test.Backoff)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package hopper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+const (
+	DefaultMaxRedirects = 10
+)
+
+var (
+	ErrTooManyRedirects    = errors.New("Too many redirects")
+	ErrCrossDomainRedirect = errors.New("Cross domain redirect")
+)
+
+type requestContextKey struct{}
+
+// Redirect is single hop of redirect chain.
+type Redirect struct {
+	URL        *url.URL
+	StatusCode int
+}
+
+// RedirectPolicy decides which redirects are followed. Targets of
+// followed redirects are checked by filters and redirect handlers of
+// crawler, unless SkipChecks is set. Enqueue stops at first redirect, so that its target
+// is crawled as new request instead.
+type RedirectPolicy struct {
+	MaxRedirects int
+	SameDomain   bool
+	SkipChecks   bool
+	Enqueue      bool
+
+	check func(req *Request, target *http.Request) error
+}
+
+// CheckRedirect is used as CheckRedirect of http.Client.
+func (rp *RedirectPolicy) CheckRedirect(target *http.Request, via []*http.Request) error {
+	max := rp.MaxRedirects
+	if max == 0 {
+		max = DefaultMaxRedirects
+	}
+
+	if rp.Enqueue {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= max {
+		return ErrTooManyRedirects
+	}
+	if rp.SameDomain && registrableDomain(via[0].URL.Hostname()) != registrableDomain(target.URL.Hostname()) {
+		return ErrCrossDomainRedirect
+	}
+
+	req, ok := target.Context().Value(requestContextKey{}).(*Request)
+	if ok && !rp.SkipChecks && rp.check != nil {
+		return rp.check(req, target)
+	}
+
+	return nil
+}
+
+// redirects returns redirect chain which led to response, in order.
+func redirects(res *http.Response) []Redirect {
+	chain := []Redirect{}
+	if res.Request == nil {
+		return chain
+	}
+
+	for prev := res.Request.Response; prev != nil && prev.Request != nil; prev = prev.Request.Response {
+		chain = append([]Redirect{{URL: prev.Request.URL, StatusCode: prev.StatusCode}}, chain...)
+	}
+
+	return chain
+}
+
+// redirectLocation returns target of redirect response which was not
+// followed.
+func redirectLocation(res *http.Response) (*url.URL, bool) {
+	if res.StatusCode < 300 || res.StatusCode >= 400 {
+		return nil, false
+	}
+
+	location, err := res.Location()
+	if err != nil {
+		return nil, false
+	}
+
+	return location, true
+}
+
+// withRequest returns context which carries request to redirect checks.
+func withRequest(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, req)
+}
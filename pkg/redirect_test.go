@@ -0,0 +1,119 @@
+package hopper
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/chain", http.RedirectHandler("/old", http.StatusFound))
+	mux.Handle("/old", http.RedirectHandler("/dir/new", http.StatusMovedPermanently))
+	mux.HandleFunc("/dir/new", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<html><body><a href="next">Next</a></body></html>`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		Name      string
+		Seed      string
+		Redirects *RedirectPolicy
+		Filters   []Filter
+		Err       error
+		Pushed    string
+		Chain     []string
+	}{
+		{"Follow", "/chain", nil, nil, nil, "/dir/next", []string{"/chain", "/old"}},
+		{"Filtered", "/old", nil, []Filter{DisallowPaths("/dir/*")}, ErrFiltered, "", nil},
+		{"TooMany", "/chain", &RedirectPolicy{MaxRedirects: 1}, nil, ErrTooManyRedirects, "", nil},
+		{"Enqueue", "/old", &RedirectPolicy{Enqueue: true}, nil, nil, "/dir/new", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			crawler := Crawler{Client: srv.Client(), Redirects: test.Redirects, Filters: test.Filters, AllowedDepth: 1, Delay: TestDelay}
+			crawler.Init()
+
+			var visitErr error
+			var chain []string
+			pushed := ""
+			crawler.OnError(func(r *Request, err error) {
+				if r.URL.Path == test.Seed {
+					visitErr = err
+				}
+			})
+			crawler.OnResponse(func(r *Response) error {
+				for _, redirect := range r.Redirects {
+					chain = append(chain, redirect.URL.Path)
+				}
+				return nil
+			})
+			crawler.OnPush(func(r *Request) error {
+				if r.URL.Path != test.Seed {
+					pushed = r.URL.Path
+				}
+				return nil
+			})
+
+			crawler.Run(srv.URL + test.Seed)
+
+			if !errors.Is(visitErr, test.Err) {
+				t.Fatalf("err = %v, want %v", visitErr, test.Err)
+			}
+			if pushed != test.Pushed {
+				t.Fatalf("pushed = %s, want %s", pushed, test.Pushed)
+			}
+			if len(chain) != len(test.Chain) || (len(chain) > 0 && (chain[0] != test.Chain[0] || chain[1] != test.Chain[1])) {
+				t.Fatalf("Response.Redirects = %v, want %v", chain, test.Chain)
+			}
+		})
+	}
+}
+
+func TestRedirectHops(t *testing.T) {
+	var mu sync.Mutex
+	visits := map[string]int{}
+
+	mux := http.NewServeMux()
+	mux.Handle("/chain", http.RedirectHandler("/old", http.StatusFound))
+	mux.Handle("/old", http.RedirectHandler("/new", http.StatusMovedPermanently))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		visits[r.URL.Path]++
+		mu.Unlock()
+		io.WriteString(w, `<html><body><a href="/new">Self</a></body></html>`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	crawler := Crawler{Client: srv.Client(), AllowedDepth: 2, Delay: TestDelay}
+	crawler.Init()
+
+	requested := []string{}
+	redirected := []string{}
+	crawler.OnRequest(func(r *Request) error {
+		requested = append(requested, r.URL.Path)
+		return nil
+	})
+	crawler.OnRedirect(func(r *Request) error {
+		redirected = append(redirected, r.URL.Path)
+		return nil
+	})
+
+	crawler.Run(srv.URL + "/chain")
+
+	if strings.Join(requested, " ") != "/chain" || strings.Join(redirected, " ") != "/old /new" {
+		t.Fatalf("requested = %v, redirected = %v, want [/chain] and [/old /new]", requested, redirected)
+	}
+	if visits["/new"] != 1 {
+		t.Fatalf("visits of /new = %d, want 1", visits["/new"])
+	}
+}
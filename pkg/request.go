@@ -2,8 +2,10 @@ package hopper
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -16,6 +18,37 @@ type Request struct {
 	Method string
 	URL    *url.URL
 	Depth  int
+	Seed   *url.URL
+	Hops   int
+	// Session names cookie session of request, it is inherited by
+	// discovered requests.
+	Session string
+	// Proxy overrides proxy selected by client, it is inherited by
+	// discovered requests.
+	Proxy *url.URL
+	// Fetcher names fetcher of crawler request is fetched with, it is
+	// inherited by discovered requests. Client fetches requests which
+	// name none.
+	Fetcher string
+
+	Priority float64
+	Anchor   string
+	// SitemapPriority is priority sitemap listed url with, 0 if it was
+	// not discovered in sitemap.
+	SitemapPriority float64
+	// Relevance of fetched page, for pushed requests it is inherited
+	// from page they were discovered on.
+	Relevance float64
+
+	// Recrawl marks revisit of already seen url, which is not sent
+	// before NotBefore.
+	Recrawl   bool
+	NotBefore time.Time
+
+	// Body is sent with request, GraphQL is query body was encoded
+	// from. They are not inherited.
+	Body    *Body
+	GraphQL *GraphQLQuery
 
 	Headers    http.Header
 	Properties map[string]any
@@ -39,11 +72,32 @@ func (req Request) New(method string, uri string) (*Request, error) {
 	}
 	parsed.Fragment = ""
 
+	if normalizer, ok := req.Properties["Normalizer"].(*Normalizer); ok {
+		normalizer.Normalize(parsed)
+	}
+
 	req.URL = parsed
 	req.Method = method
+	req.Body = nil
+	req.GraphQL = nil
+	req.Anchor = ""
+	req.SitemapPriority = 0
+	req.Recrawl = false
+	req.NotBefore = time.Time{}
     req.Headers = http.Header{}
 	req.Depth++
 
+	// Seed is the first url of each crawl path and hops count how many
+	// consecutive requests were made outside of its scope.
+	scope, _ := req.Properties["Scope"].(Scope)
+	if req.Seed == nil {
+		req.Seed = parsed
+	} else if scope.Contains(req.Seed, parsed) {
+		req.Hops = 0
+	} else {
+		req.Hops++
+	}
+
     // NOTE: Because properties are a map we are deep copying it to new request
     newProperties := map[string]any{}
     for k, v := range req.Properties {
@@ -51,35 +105,78 @@ func (req Request) New(method string, uri string) (*Request, error) {
     }
     req.Properties = newProperties
 
-	if !req.Valid() {
-		return nil, errors.New("Invalid request")
+	if err := req.Validate(); err != nil {
+		return nil, err
 	}
 
 	return &req, nil
 }
 
+// NewPost creates request which posts body to uri.
+func (req Request) NewPost(uri string, body *Body) (*Request, error) {
+	created, err := req.New(http.MethodPost, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	created.Body = body
+	return created, nil
+}
+
+// NewForm creates request which submits values to action as html form
+// with given method would, in query of action or urlencoded body.
+func (req Request) NewForm(method string, action string, values url.Values) (*Request, error) {
+	if strings.ToUpper(method) == http.MethodPost {
+		return req.NewPost(action, FormBody(values))
+	}
+
+	uri, err := req.URL.Parse(action)
+	if err != nil {
+		return nil, err
+	}
+	uri.RawQuery = values.Encode()
+
+	return req.New(http.MethodGet, uri.String())
+}
+
+// Key identifies request for deduplication. It is url for requests
+// without body, method, url and body hash otherwise.
+func (req *Request) Key() string {
+	if req.Body == nil && (req.Method == "" || req.Method == http.MethodGet) {
+		return req.URL.String()
+	}
+
+	key := req.Method + " " + req.URL.String()
+	if req.Body != nil {
+		key += " " + req.Body.Hash()
+	}
+	return key
+}
+
 func (req *Request) Valid() bool {
-   	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
-		return false
+	return req.Validate() == nil
+}
+
+// Validate returns error describing why request should not be crawled.
+func (req *Request) Validate() error {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("Scheme %s not supported: %w", req.URL.Scheme, ErrFiltered)
 	}
 
 	if req.Depth > req.Properties["AllowedDepth"].(int) {
-		return false
+		return fmt.Errorf("Depth %d exceeds allowed depth: %w", req.Depth, ErrFiltered)
 	}
 
-	for _, host := range req.Properties["AllowedDomains"].([]string) {
-		if req.URL.Hostname() == host {
-			continue
-		}
-		return false
+	if hops, ok := req.Properties["OffsiteHops"].(int); ok && req.Hops > hops {
+		return fmt.Errorf("URL %s is %d hops outside of seed scope: %w", req.URL, req.Hops, ErrFiltered)
 	}
 
-	for _, host := range req.Properties["DisallowedDomains"].([]string) {
-		if req.URL.Hostname() != host {
-			continue
+	filters, _ := req.Properties["Filters"].([]Filter)
+	for _, filter := range filters {
+		if err := filter(req.URL); err != nil {
+			return err
 		}
-		return false
 	}
 
-	return true
+	return nil
 }
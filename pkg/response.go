@@ -1,10 +1,16 @@
 package hopper
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/net/html"
 )
@@ -17,6 +23,32 @@ type Response struct {
 
     Headers http.Header
     Properties map[string]any
+
+	Fingerprint *Fingerprint
+	DuplicateOf *url.URL
+	NoFollow    bool
+	Change      Change
+	NotModified bool
+	// URL is final url of response, Redirects chain which led to it.
+	URL       *url.URL
+	Redirects []Redirect
+	// Discovered requests are added by handlers and pushed along with
+	// links of response.
+	Discovered []*Request
+	// Truncated reports body was cut at ContentLength or BodyTimeout.
+	Truncated bool
+	// Download is file body was streamed to, instead of being buffered.
+	Download *Download
+	// Blob is reference of body in archive storage.
+	Blob string
+	// Protocol response was received with and Timing of its request.
+	Protocol string
+	Timing   Timing
+
+	raw    io.ReadCloser
+	closed bool
+	body   []byte
+	json   *any
 }
 
 func NewResponse(r *http.Response, prop map[string]any, req *Request) (*Response, error) {
@@ -26,20 +58,102 @@ func NewResponse(r *http.Response, prop map[string]any, req *Request) (*Response
         Request: req,
         Headers: r.Header,
         Properties: prop,
+        raw: r.Body,
     }
 
+	res.URL = req.URL
+	if r.Request != nil {
+		res.URL = r.Request.URL
+	}
+	res.Redirects = redirects(r)
+	res.Protocol = r.Proto
+	res.Timing = requestTiming(r.Request)
+
     if !res.Valid() {
-        return nil, errors.New("Invalid response")
+        return nil, &StatusError{StatusCode: res.StatusCode}
     }
 
+	if res.StatusCode == http.StatusNotModified {
+		res.NotModified = true
+		res.Change = ChangeUnchanged
+	}
+
     return res, nil
 }
 
+// StatusError is returned for response with status crawler doesn't accept.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Invalid response %d", e.StatusCode)
+}
+
+// Do returns requests of links found in response. Links which were
+// rejected, e.g. by filters, are reported in joined error along with
+// requests of the rest.
 func (res *Response) Do() ([]*Request, error) {
-    discovered := []*Request{}
+    discovered := append([]*Request{}, res.Discovered...)
+	if res.NoFollow {
+		return discovered, nil
+	}
+	if res.IsJSON() {
+		return discovered, nil
+	}
+
+	body, err := res.Bytes()
+	if err != nil {
+		return discovered, err
+	}
+
+	// Links are relative to final url of response.
+	base := *res.Request
+	base.URL = res.URL
+
+	// Assets are crawled at depth of document which references them,
+	// links found in scripts and form submissions one level deeper.
+	scan, _ := res.Properties["ScanScripts"].(bool)
+	submit, _ := res.Properties["SubmitForms"].(bool)
+	rejected := []error{}
+	add := func(uri string, resolved *Request, err error) *Request {
+		if err != nil {
+			rejected = append(rejected, fmt.Errorf("Link %s: %w", uri, err))
+			return nil
+		}
+		discovered = append(discovered, resolved)
+		return resolved
+	}
+	asset := func(uri string) {
+		resolved, err := res.page(uri)
+		add(uri, resolved, err)
+	}
+	script := func(script string) {
+		for _, uri := range scriptLinks(script) {
+			resolved, err := base.New("GET", uri)
+			add(uri, resolved, err)
+		}
+	}
+
+	if res.IsCSS() {
+		for _, uri := range cssLinks(string(body)) {
+			asset(uri)
+		}
+		return discovered, errors.Join(rejected...)
+	}
+	if res.IsJavaScript() {
+		if scan {
+			script(string(body))
+		}
+		return discovered, errors.Join(rejected...)
+	}
+	if res.IsXML() {
+		if listed, errs, ok := sitemapLinks(&base, body); ok {
+			return append(discovered, listed...), errors.Join(append(rejected, errs...)...)
+		}
+	}
 
-    reader := io.LimitReader(res.Body, res.Properties["ContentLength"].(int64))
-    node, err := html.Parse(reader)
+    node, err := html.Parse(bytes.NewReader(body))
     if err != nil {
         return discovered, err
     }
@@ -53,11 +167,36 @@ func (res *Response) Do() ([]*Request, error) {
                     continue
                 }
 				if attr.Key == "href" {
-					resolved, err := res.Request.New("GET", attr.Val)
-					if err != nil {
-						continue
+					resolved, err := base.New("GET", attr.Val)
+					if add(attr.Val, resolved, err) != nil {
+						resolved.Anchor = nodeText(n)
 					}
-					discovered = append(discovered, resolved)
+				}
+			}
+		}
+		if n.Type == html.ElementNode {
+			for _, uri := range cssLinks(attribute(n, "style")) {
+				asset(uri)
+			}
+
+			switch {
+			case n.Data == "link" && hasRel(n, "stylesheet"):
+				asset(attribute(n, "href"))
+			case n.Data == "style" && n.FirstChild != nil:
+				for _, uri := range cssLinks(n.FirstChild.Data) {
+					asset(uri)
+				}
+			case n.Data == "script" && scan:
+				if src := attribute(n, "src"); src != "" {
+					asset(src)
+				} else if n.FirstChild != nil {
+					script(n.FirstChild.Data)
+				}
+			// Only forms without side effects are submitted.
+			case n.Data == "form" && submit:
+				if form := parseForm(res.URL, n); form.Method == http.MethodGet {
+					resolved, err := form.Request(&base)
+					add(form.Action.String(), resolved, err)
 				}
 			}
 		}
@@ -67,10 +206,73 @@ func (res *Response) Do() ([]*Request, error) {
 	}
 	f(node)
 
-    return discovered, nil
+    return discovered, errors.Join(rejected...)
+}
+
+// Bytes reads body up to ContentLength within BodyTimeout and buffers
+// it, so that it can be read again by following handlers.
+func (res *Response) Bytes() ([]byte, error) {
+	if res.body == nil {
+		limit, ok := res.Properties["ContentLength"].(int64)
+		if !ok || limit <= 0 {
+			limit = math.MaxInt64 - 1
+		}
+
+		var timedOut atomic.Bool
+		if timeout, ok := res.Properties["BodyTimeout"].(time.Duration); ok && timeout > 0 {
+			// Closing received body unblocks pending read.
+			timer := time.AfterFunc(timeout, func() {
+				timedOut.Store(true)
+				rawBody(res.raw).Close()
+			})
+			defer timer.Stop()
+		}
+
+		body, err := io.ReadAll(io.LimitReader(res.raw, limit+1))
+		if err != nil && timedOut.Load() {
+			res.Truncated = true
+			err = nil
+		}
+		if int64(len(body)) > limit {
+			res.Truncated = true
+			body = body[:limit]
+		}
+		res.Close()
+		if err != nil {
+			return nil, err
+		}
+		res.body = body
+		if !res.Timing.start.IsZero() {
+			res.Timing.Total = time.Since(res.Timing.start)
+		}
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(res.body))
+	return res.body, nil
+}
+
+// Close discards unread rest of body and closes it, so that connection
+// can be reused. Buffered body stays readable.
+func (res *Response) Close() {
+	if res.closed {
+		return
+	}
+
+	drainBody(res.raw)
+	res.closed = true
 }
 
 func (res *Response) Valid() bool {
+	// Revisits report pages which disappeared instead of failing.
+	if res.Request.Recrawl && (res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone) {
+		return true
+	}
+
+	// Conditional requests report unchanged pages with 304.
+	if res.StatusCode == http.StatusNotModified {
+		return true
+	}
+
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
 		return false 
 	}
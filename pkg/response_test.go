@@ -0,0 +1,51 @@
+package hopper
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// discover runs Response.Do on body served with contentType from uri. The
+// request is created from a seed with properties set on top of defaults.
+func discover(t *testing.T, uri, contentType, body string, properties map[string]any) (*Response, []*Request) {
+	t.Helper()
+
+	seed := &Request{}
+	seed.Init()
+	seed.Properties["AllowedDepth"] = 10
+	for key, value := range properties {
+		seed.Properties[key] = value
+	}
+
+	req, err := seed.New(http.MethodGet, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpRes := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+	res, err := NewResponse(httpRes, req.Properties, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discovered, err := res.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, discovered
+}
+
+// paths returns the url paths of requests.
+func paths(requests []*Request) []string {
+	got := []string{}
+	for _, r := range requests {
+		got = append(got, r.URL.Path)
+	}
+	return got
+}
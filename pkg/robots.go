@@ -15,16 +15,19 @@ var ErrRobotsTxtExcluded = errors.New("Excluded path")
 
 type RobotsTxtMiddleware struct {
     Client *Client
+    // Token is user agent rules are evaluated for, regardless of user
+    // agent requests are sent with. Client's User-Agent is used if empty.
+    Token string
 
     groups sync.Map
     robots sync.Map
 }
 
 func RobotsTxt(crawler *Crawler) {
-    rt := &RobotsTxtMiddleware{Client: crawler.client}
+    rt := &RobotsTxtMiddleware{Client: crawler.client, Token: crawler.RobotToken}
 
-    crawler.OnRequest(func(r *Request) error {
-        _, exists := rt.GetGroup(r.URL.Hostname(), r.Headers.Get("User-Agent"))
+    check := func(r *Request) error {
+        _, exists := rt.GetGroup(r.URL.Hostname(), rt.Token)
         if !exists {
             robots, err := rt.Fetch(r.URL.Host)
             if err != nil {
@@ -33,19 +36,21 @@ func RobotsTxt(crawler *Crawler) {
             rt.SetGroup(r.URL.Hostname(), robots)
         }
 
-        if !rt.Crawlable(r.URL, r.Headers.Get("User-Agent")) {
+        if !rt.Crawlable(r.URL, rt.Token) {
             return fmt.Errorf("Robots: %w", ErrRobotsTxtExcluded)
         }
 
         return nil
-    })
+    }
+    crawler.OnRequest(check)
+    crawler.OnRedirect(check)
 
     crawler.OnPush(func(r *Request) error {
-        if !rt.Crawlable(r.URL, r.Headers.Get("User-Agent")) {
+        if !rt.Crawlable(r.URL, rt.Token) {
             return fmt.Errorf("Robots: %w", ErrRobotsTxtExcluded)
         }
 
-        r.Properties["Delay"] = rt.GetDelay(r.URL, r.Headers.Get("User-Agent"))
+        r.Properties["Delay"] = rt.GetDelay(r.URL, rt.Token)
 
         return nil
     })
@@ -73,13 +78,20 @@ func (rt *RobotsTxtMiddleware) Fetch(host string) (*robotstxt.RobotsData, error)
 }
 
 func (rt *RobotsTxtMiddleware) SetGroup(host string, robots *robotstxt.RobotsData) {
-	group := robots.FindGroup(rt.Client.Headers.Get("User-Agent"))
+	group := robots.FindGroup(rt.token())
 	rt.groups.Store(host, group)
 	rt.robots.Store(host, robots)
 }
 
+func (rt *RobotsTxtMiddleware) token() string {
+	if rt.Token == "" {
+		return rt.Client.Headers.Get("User-Agent")
+	}
+	return rt.Token
+}
+
 func (rt *RobotsTxtMiddleware) GetGroup(host string, userAgent string) (*robotstxt.Group, bool) {
-	if userAgent == rt.Client.Headers.Get("User-Agent") || userAgent == "" {
+	if userAgent == rt.token() || userAgent == "" {
 		group, exists := rt.groups.Load(host)
 		if !exists {
 			return nil, exists
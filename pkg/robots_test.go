@@ -1,6 +1,7 @@
 package hopper
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -84,7 +85,7 @@ func TestRobotsTxt(t *testing.T) {
 
         for _, result := range results {
             t.Run(result.Path, func(t *testing.T) {
-                if result.Path == "" {
+                if result.Path == "/" {
                     return
                 }
 
@@ -114,7 +115,7 @@ func TestRobotsTxt(t *testing.T) {
 
         for path, err := range results {
             t.Run(path, func(t *testing.T) {
-                if err != nil && (path == "/2" || path == "") {
+                if err != nil && (path == "/2" || path == "/") {
                     t.Fatalf("err = %T, want nil", err)
                 } 
 
@@ -124,6 +125,37 @@ func TestRobotsTxt(t *testing.T) {
             })
         }
 	})
+
+	t.Run("WithProfiles", func(t *testing.T) {
+		profiles := &HeaderProfiles{Profiles: []HeaderProfile{
+			{UserAgent: "Mozilla/5.0 (X11; Linux x86_64)", Accept: "text/html"},
+			{UserAgent: "Mozilla/5.0 (Macintosh)", AcceptLanguage: "en-US"},
+		}}
+		crawler := Crawler{Client: srv.Client(), UserAgent: "hopper/disallow", Profiles: profiles, Delay: TestDelay}
+		crawler.Init()
+		RobotsTxt(&crawler)
+
+		agents := map[string]string{}
+		excluded := map[string]bool{}
+		crawler.OnResponse(func(r *Response) error {
+			agents[r.Request.URL.Path] = r.Request.Headers.Get("User-Agent")
+			return nil
+		})
+		crawler.OnError(func(r *Request, err error) {
+			excluded[r.URL.Path] = errors.Is(err, ErrRobotsTxtExcluded)
+		})
+
+		crawler.Run(srv.URL)
+
+		if !excluded["/1"] || !excluded["/3"] || excluded["/2"] {
+			t.Fatalf("excluded = %v, want /1 and /3", excluded)
+		}
+		for path, agent := range agents {
+			if !strings.HasPrefix(agent, "Mozilla") {
+				t.Fatalf("User-Agent of %s = %s, want profile user agent", path, agent)
+			}
+		}
+	})
 }
 
 func TestGetDelay(t *testing.T) {
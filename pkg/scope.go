@@ -0,0 +1,38 @@
+package hopper
+
+import (
+	"net/url"
+	"strings"
+)
+
+type Scope int
+
+const (
+	// ScopeAny does not restrict crawl to seed.
+	ScopeAny Scope = iota
+	// ScopeHost keeps crawl on seed hostname.
+	ScopeHost
+	// ScopeDomain keeps crawl on seed registrable domain and its subdomains.
+	ScopeDomain
+	// ScopePrefix keeps crawl on seed host under directory of seed path.
+	ScopePrefix
+)
+
+// Contains reports whether uri is inside scope of seed.
+func (s Scope) Contains(seed *url.URL, uri *url.URL) bool {
+	switch s {
+	case ScopeHost:
+		return strings.EqualFold(seed.Hostname(), uri.Hostname())
+	case ScopeDomain:
+		return registrableDomain(seed.Hostname()) == registrableDomain(uri.Hostname())
+	case ScopePrefix:
+		if !strings.EqualFold(seed.Host, uri.Host) {
+			return false
+		}
+		prefix := seed.EscapedPath()
+		prefix = prefix[:strings.LastIndex(prefix, "/")+1]
+		return strings.HasPrefix(uri.EscapedPath(), prefix) || uri.EscapedPath()+"/" == prefix
+	default:
+		return true
+	}
+}
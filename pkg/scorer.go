@@ -0,0 +1,89 @@
+package hopper
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Scorer assigns priority to request before it is pushed to queue.
+// Requests of a host with higher priority are crawled first.
+type Scorer func(*Request) float64
+
+// BFSScorer prefers shallow requests, making crawl breadth-first.
+func BFSScorer(req *Request) float64 {
+	return -float64(req.Depth)
+}
+
+// DFSScorer prefers deep requests, making crawl depth-first.
+func DFSScorer(req *Request) float64 {
+	return float64(req.Depth)
+}
+
+// PatternScorer adds weight for each of patterns matching request url.
+func PatternScorer(weight float64, patterns ...*regexp.Regexp) Scorer {
+	return func(req *Request) float64 {
+		score := 0.0
+		for _, pattern := range patterns {
+			if pattern.MatchString(req.URL.String()) {
+				score += weight
+			}
+		}
+		return score
+	}
+}
+
+// AnchorScorer scores request by fraction of keywords found in text of
+// the link it was discovered from, multiplied by weight.
+func AnchorScorer(weight float64, keywords ...string) Scorer {
+	return func(req *Request) float64 {
+		if len(keywords) == 0 {
+			return 0
+		}
+
+		anchor := strings.ToLower(req.Anchor)
+		found := 0
+		for _, keyword := range keywords {
+			if strings.Contains(anchor, strings.ToLower(keyword)) {
+				found++
+			}
+		}
+		return weight * float64(found) / float64(len(keywords))
+	}
+}
+
+// SitemapScorer scores request by priority sitemap listed it with,
+// multiplied by weight.
+func SitemapScorer(weight float64) Scorer {
+	return func(req *Request) float64 {
+		return weight * req.SitemapPriority
+	}
+}
+
+// InLinkScorer scores request by logarithm of number of times its url was
+// pushed so far, multiplied by weight. Queued request is raised each time
+// another link to it is pushed.
+func InLinkScorer(weight float64) Scorer {
+	var mu sync.Mutex
+	counts := map[string]int{}
+
+	return func(req *Request) float64 {
+		mu.Lock()
+		defer mu.Unlock()
+
+		counts[req.URL.String()]++
+		return weight * math.Log1p(float64(counts[req.URL.String()]))
+	}
+}
+
+// CombineScorers returns scorer which sums scores of all scorers.
+func CombineScorers(scorers ...Scorer) Scorer {
+	return func(req *Request) float64 {
+		score := 0.0
+		for _, scorer := range scorers {
+			score += scorer(req)
+		}
+		return score
+	}
+}
@@ -0,0 +1,72 @@
+package hopper
+
+import (
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// jsString matches string literals of script. Template literals are
+// matched whole, so that ones with substitutions, whose value is not known
+// statically, are rejected as links rather than split at them.
+var jsString = regexp.MustCompile(`"((?:[^"\\\n]|\\.)*)"|'((?:[^'\\\n]|\\.)*)'|` + "`((?:[^`\\\\]|\\\\.)*)`")
+
+// scriptExtensions are extensions of paths which are taken as links when
+// they are relative.
+var scriptExtensions = map[string]bool{
+	".html": true, ".htm": true, ".php": true, ".js": true, ".mjs": true, ".css": true, ".json": true, ".xml": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".ico": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".mp4": true, ".webm": true, ".mp3": true,
+}
+
+// scriptLinks returns string literals of script which look like urls.
+// Scanner is conservative, absolute http urls are taken, paths only when
+// they are rooted or relative with known extension.
+func scriptLinks(script string) []string {
+	links := []string{}
+	for _, match := range jsString.FindAllStringSubmatch(script, -1) {
+		for _, literal := range match[1:] {
+			if isScriptLink(literal) {
+				links = append(links, literal)
+				break
+			}
+		}
+	}
+	return links
+}
+
+func isScriptLink(literal string) bool {
+	if len(literal) < 2 || len(literal) > 2048 || strings.ContainsAny(literal, " \t\r\n\\<>{}[]|^*'\"`") {
+		return false
+	}
+
+	lower := strings.ToLower(literal)
+	switch {
+	case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"):
+		return len(literal) > len("https://")
+	case strings.HasPrefix(literal, "//"):
+		host, _, _ := strings.Cut(literal[2:], "/")
+		return strings.Contains(host, ".")
+	case strings.HasPrefix(literal, "/"):
+		// Rooted paths need letters, which rules out regexes and dates.
+		return strings.IndexFunc(literal, isLetter) >= 0
+	case strings.HasPrefix(literal, "./"), strings.HasPrefix(literal, "../"):
+		return scriptExtensions[strings.ToLower(path.Ext(strings.SplitN(literal, "?", 2)[0]))]
+	default:
+		return false
+	}
+}
+
+func isLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+// IsJavaScript reports whether response is script.
+func (res *Response) IsJavaScript() bool {
+	mediaType, _, err := mime.ParseMediaType(res.Headers.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(mediaType, "/javascript") || strings.HasSuffix(mediaType, "/ecmascript")
+}
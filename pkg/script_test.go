@@ -0,0 +1,58 @@
+package hopper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScriptLinks(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Script string
+		Want   []string
+	}{
+		{"Quotes", "a('/double'); b(\"/single\"); c(`/template`)", []string{"/double", "/single", "/template"}},
+		{"Absolute", `a = "https://example.com/a"; b = "//cdn.example.com/b.js"; c = "http://"; d = "//localhost/d"`, []string{"https://example.com/a", "//cdn.example.com/b.js"}},
+		{"Relative", `a = "./chunk.js"; b = "../page.html?v=1"; c = "./unknown.ext"; d = "bare.js"`, []string{"./chunk.js", "../page.html?v=1"}},
+		{"Regexes", `a = new RegExp("^/api/(\\d+)$"); b = "/[a-z]+/"; c = "/api/.*"; d = "/^(?:foo|bar)/"`, []string{}},
+		{"Dates", `a = "/"; b = "/2024/01/"; c = "//"`, []string{}},
+		{"MIME", `a = "text/html"; b = 'image/png'; c = "application/json"`, []string{}},
+		{"Templates", "a = `/u/${id}`; b = `/static/${name}.js`; c = `/plain`", []string{"/plain"}},
+		{"Whitespace", `a = "/a b"; b = "/a\tb"; c = "<a href='/x'>"`, []string{}},
+		{"Length", `a = "/` + strings.Repeat("a", 2047) + `"; b = "/` + strings.Repeat("a", 2048) + `"`, []string{"/" + strings.Repeat("a", 2047)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			got := scriptLinks(test.Script)
+			if strings.Join(got, " ") != strings.Join(test.Want, " ") {
+				t.Fatalf("scriptLinks() = %v, want %v", got, test.Want)
+			}
+		})
+	}
+}
+
+func TestScanScripts(t *testing.T) {
+	tests := []struct {
+		Name        string
+		ContentType string
+		Body        string
+		ScanScripts bool
+		Want        []string
+	}{
+		{"ScriptsDisabled", "text/html", `<script src="/app.js"></script><script>fetch("/api/items")</script>`, false, []string{}},
+		{"InlineScripts", "text/html", `<script src="/app.js"></script><script>fetch("/api/items"); x = "text/html"; y = "a b/c"</script>`, true, []string{"/app.js", "/api/items"}},
+		{"Script", "application/javascript", "load('./chunk.js'); go(`/page`); re = '/'; tpl = `/u/${id}`; cdn = \"//cdn.example.com/lib.js\"; mime = 'image/png'", true, []string{"/js/chunk.js", "/page", "/lib.js"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, discovered := discover(t, "http://example.com/js/page", test.ContentType, test.Body, map[string]any{"ScanScripts": test.ScanScripts})
+
+			got := paths(discovered)
+			if strings.Join(got, " ") != strings.Join(test.Want, " ") {
+				t.Fatalf("Response.Do() = %v, want %v", got, test.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package hopper
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"math"
+	"sync"
+)
+
+const (
+	DefaultBloomCapacity          = 1 << 20
+	DefaultBloomFalsePositiveRate = 0.001
+
+	// bloomGrowth and bloomTightening control how new filter slices are sized.
+	bloomGrowth     = 2
+	bloomTightening = 0.85
+)
+
+var ErrInvalidSeenSet = errors.New("Invalid seen set data")
+
+// SeenSet records keys of urls that were already pushed to URLQueue.
+// Implementations are safe for concurrent use and can be serialised,
+// so they can be stored together with frontier.
+type SeenSet interface {
+	// Add records key and reports whether it was not seen before.
+	Add(key string) bool
+	Has(key string) bool
+	Len() int
+
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// MapSeenSet is exact seen set storing full keys.
+type MapSeenSet struct {
+	sync.Mutex
+
+	keys map[string]struct{}
+}
+
+func (s *MapSeenSet) Add(key string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.keys == nil {
+		s.keys = map[string]struct{}{}
+	}
+	if _, exists := s.keys[key]; exists {
+		return false
+	}
+	s.keys[key] = struct{}{}
+
+	return true
+}
+
+func (s *MapSeenSet) Has(key string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	_, exists := s.keys[key]
+	return exists
+}
+
+func (s *MapSeenSet) Len() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.keys)
+}
+
+func (s *MapSeenSet) MarshalBinary() ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint64(len(s.keys)))
+	for key := range s.keys {
+		binary.Write(buf, binary.BigEndian, uint32(len(key)))
+		buf.WriteString(key)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (s *MapSeenSet) UnmarshalBinary(data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	r := bytes.NewReader(data)
+	var n uint64
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return ErrInvalidSeenSet
+	}
+
+	s.keys = map[string]struct{}{}
+	for i := uint64(0); i < n; i++ {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return ErrInvalidSeenSet
+		}
+		key := make([]byte, size)
+		if _, err := io.ReadFull(r, key); err != nil {
+			return ErrInvalidSeenSet
+		}
+		s.keys[string(key)] = struct{}{}
+	}
+
+	return nil
+}
+
+// HashedSeenSet is exact seen set storing only 64 or 128-bit FNV hashes
+// of keys. Bits defaults to 64, collisions become likely only around
+// billions of keys for 64 bits.
+type HashedSeenSet struct {
+	sync.Mutex
+
+	Bits int
+
+	keys map[[2]uint64]struct{}
+}
+
+func (s *HashedSeenSet) hash(key string) [2]uint64 {
+	if s.Bits == 128 {
+		h := fnv.New128a()
+		h.Write([]byte(key))
+		sum := h.Sum(nil)
+		return [2]uint64{binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:])}
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return [2]uint64{h.Sum64(), 0}
+}
+
+func (s *HashedSeenSet) Add(key string) bool {
+	hash := s.hash(key)
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.keys == nil {
+		s.keys = map[[2]uint64]struct{}{}
+	}
+	if _, exists := s.keys[hash]; exists {
+		return false
+	}
+	s.keys[hash] = struct{}{}
+
+	return true
+}
+
+func (s *HashedSeenSet) Has(key string) bool {
+	hash := s.hash(key)
+
+	s.Lock()
+	defer s.Unlock()
+
+	_, exists := s.keys[hash]
+	return exists
+}
+
+func (s *HashedSeenSet) Len() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.keys)
+}
+
+func (s *HashedSeenSet) MarshalBinary() ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint8(s.Bits))
+	binary.Write(buf, binary.BigEndian, uint64(len(s.keys)))
+	for hash := range s.keys {
+		binary.Write(buf, binary.BigEndian, hash)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (s *HashedSeenSet) UnmarshalBinary(data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	r := bytes.NewReader(data)
+	var bits uint8
+	var n uint64
+	if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+		return ErrInvalidSeenSet
+	}
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return ErrInvalidSeenSet
+	}
+
+	s.Bits = int(bits)
+	s.keys = make(map[[2]uint64]struct{}, n)
+	for i := uint64(0); i < n; i++ {
+		var hash [2]uint64
+		if err := binary.Read(r, binary.BigEndian, &hash); err != nil {
+			return ErrInvalidSeenSet
+		}
+		s.keys[hash] = struct{}{}
+	}
+
+	return nil
+}
+
+type bloomSlice struct {
+	bits     []uint64
+	m        uint64
+	k        uint64
+	capacity uint64
+	count    uint64
+}
+
+func newBloomSlice(capacity uint64, rate float64) *bloomSlice {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Ceil(math.Log2(1 / rate)))
+	if m < 64 {
+		m = 64
+	}
+	if k < 1 {
+		k = 1
+	}
+
+	return &bloomSlice{bits: make([]uint64, (m+63)/64), m: m, k: k, capacity: capacity}
+}
+
+func (b *bloomSlice) has(h1, h2 uint64) bool {
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (b *bloomSlice) add(h1, h2 uint64) {
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		b.bits[pos/64] |= 1 << (pos % 64)
+	}
+	b.count++
+}
+
+// BloomSeenSet is probabilistic seen set based on scalable Bloom filter.
+// It never reports unseen key for key that was added, but may with
+// FalsePositiveRate probability report key as seen when it was not.
+// Filter grows by adding slices with tighter rates once Capacity is filled.
+type BloomSeenSet struct {
+	sync.Mutex
+
+	Capacity          int
+	FalsePositiveRate float64
+
+	slices []*bloomSlice
+	count  int
+}
+
+func (s *BloomSeenSet) hash(key string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(key))
+	sum := h.Sum(nil)
+
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+func (s *BloomSeenSet) init() {
+	if s.Capacity <= 0 {
+		s.Capacity = DefaultBloomCapacity
+	}
+	if s.FalsePositiveRate <= 0 || s.FalsePositiveRate >= 1 {
+		s.FalsePositiveRate = DefaultBloomFalsePositiveRate
+	}
+
+	// First slice gets tighter rate so that compound rate of all slices
+	// stays below FalsePositiveRate.
+	rate := s.FalsePositiveRate * (1 - bloomTightening)
+	s.slices = []*bloomSlice{newBloomSlice(uint64(s.Capacity), rate)}
+}
+
+func (s *BloomSeenSet) has(h1, h2 uint64) bool {
+	for _, slice := range s.slices {
+		if slice.has(h1, h2) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *BloomSeenSet) Add(key string) bool {
+	h1, h2 := s.hash(key)
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.slices == nil {
+		s.init()
+	}
+	if s.has(h1, h2) {
+		return false
+	}
+
+	last := s.slices[len(s.slices)-1]
+	if last.count >= last.capacity {
+		rate := s.FalsePositiveRate * (1 - bloomTightening) * math.Pow(bloomTightening, float64(len(s.slices)))
+		last = newBloomSlice(last.capacity*bloomGrowth, rate)
+		s.slices = append(s.slices, last)
+	}
+	last.add(h1, h2)
+	s.count++
+
+	return true
+}
+
+func (s *BloomSeenSet) Has(key string) bool {
+	h1, h2 := s.hash(key)
+
+	s.Lock()
+	defer s.Unlock()
+
+	return s.has(h1, h2)
+}
+
+// Len returns number of keys that were added as unseen.
+func (s *BloomSeenSet) Len() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.count
+}
+
+func (s *BloomSeenSet) MarshalBinary() ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint64(s.Capacity))
+	binary.Write(buf, binary.BigEndian, s.FalsePositiveRate)
+	binary.Write(buf, binary.BigEndian, uint64(s.count))
+	binary.Write(buf, binary.BigEndian, uint32(len(s.slices)))
+	for _, slice := range s.slices {
+		binary.Write(buf, binary.BigEndian, [4]uint64{slice.m, slice.k, slice.capacity, slice.count})
+		binary.Write(buf, binary.BigEndian, slice.bits)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (s *BloomSeenSet) UnmarshalBinary(data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	r := bytes.NewReader(data)
+	var capacity, count uint64
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &capacity); err != nil {
+		return ErrInvalidSeenSet
+	}
+	if err := binary.Read(r, binary.BigEndian, &s.FalsePositiveRate); err != nil {
+		return ErrInvalidSeenSet
+	}
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return ErrInvalidSeenSet
+	}
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return ErrInvalidSeenSet
+	}
+
+	s.Capacity = int(capacity)
+	s.count = int(count)
+	s.slices = make([]*bloomSlice, n)
+	for i := range s.slices {
+		var header [4]uint64
+		if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+			return ErrInvalidSeenSet
+		}
+		slice := &bloomSlice{m: header[0], k: header[1], capacity: header[2], count: header[3]}
+		if slice.m == 0 || uint64(r.Len()) < (slice.m+63)/64*8 {
+			return ErrInvalidSeenSet
+		}
+		slice.bits = make([]uint64, (slice.m+63)/64)
+		if err := binary.Read(r, binary.BigEndian, slice.bits); err != nil {
+			return ErrInvalidSeenSet
+		}
+		s.slices[i] = slice
+	}
+
+	return nil
+}
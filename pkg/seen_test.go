@@ -0,0 +1,56 @@
+package hopper
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSeenSet(t *testing.T) {
+	sets := map[string]func() SeenSet{
+		"Map":       func() SeenSet { return &MapSeenSet{} },
+		"Hashed64":  func() SeenSet { return &HashedSeenSet{} },
+		"Hashed128": func() SeenSet { return &HashedSeenSet{Bits: 128} },
+		"Bloom": func() SeenSet {
+			return &BloomSeenSet{Capacity: 1000, FalsePositiveRate: 0.01}
+		},
+	}
+
+	for name, newSet := range sets {
+		t.Run(name, func(t *testing.T) {
+			set := newSet()
+			for i := 0; i < 5000; i++ {
+				key := fmt.Sprintf("http://example.com/%d", i)
+				set.Add(key)
+				if set.Add(key) {
+					t.Fatalf("set.Add(%s) = true after adding, want false", key)
+				}
+			}
+
+			data, err := set.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			restored := newSet()
+			if err := restored.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 5000; i++ {
+				key := fmt.Sprintf("http://example.com/%d", i)
+				if !restored.Has(key) {
+					t.Fatalf("restored.Has(%s) = false, want true", key)
+				}
+			}
+
+			falsePositives := 0
+			for i := 0; i < 5000; i++ {
+				if restored.Has(fmt.Sprintf("http://example.org/%d", i)) {
+					falsePositives++
+				}
+			}
+			if falsePositives > 50 {
+				t.Fatalf("false positives = %d, want <= %d", falsePositives, 50)
+			}
+		})
+	}
+}
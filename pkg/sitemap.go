@@ -0,0 +1,70 @@
+package hopper
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// DefaultSitemapPriority is priority of sitemap urls which don't set it.
+const DefaultSitemapPriority = 0.5
+
+// sitemapDocument is sitemap or sitemap index.
+type sitemapDocument struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc      string   `xml:"loc"`
+		Priority *float64 `xml:"priority"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// IsXML reports whether response is xml document.
+func (res *Response) IsXML() bool {
+	mediaType, _, err := mime.ParseMediaType(res.Headers.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/xml" || mediaType == "text/xml"
+}
+
+// sitemapLinks returns requests of urls listed by sitemap, with their
+// SitemapPriority set, and of sitemaps listed by sitemap index, along
+// with errors of rejected urls. It reports false if body is not sitemap.
+func sitemapLinks(base *Request, body []byte) ([]*Request, []error, bool) {
+	document := &sitemapDocument{}
+	if err := xml.NewDecoder(bytes.NewReader(body)).Decode(document); err != nil {
+		return nil, nil, false
+	}
+	if document.XMLName.Local != "urlset" && document.XMLName.Local != "sitemapindex" {
+		return nil, nil, false
+	}
+
+	discovered := []*Request{}
+	rejected := []error{}
+	for _, entry := range document.URLs {
+		req, err := base.New("GET", strings.TrimSpace(entry.Loc))
+		if err != nil {
+			rejected = append(rejected, fmt.Errorf("Link %s: %w", entry.Loc, err))
+			continue
+		}
+		req.SitemapPriority = DefaultSitemapPriority
+		if entry.Priority != nil {
+			req.SitemapPriority = *entry.Priority
+		}
+		discovered = append(discovered, req)
+	}
+	for _, entry := range document.Sitemaps {
+		req, err := base.New("GET", strings.TrimSpace(entry.Loc))
+		if err != nil {
+			rejected = append(rejected, fmt.Errorf("Link %s: %w", entry.Loc, err))
+			continue
+		}
+		discovered = append(discovered, req)
+	}
+	return discovered, rejected, true
+}
@@ -0,0 +1,35 @@
+package hopper
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSitemap(t *testing.T) {
+	scorer := SitemapScorer(2)
+	tests := []struct {
+		Name        string
+		ContentType string
+		Body        string
+		Want        []string
+	}{
+		{"URLSet", "application/xml", `<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc> http://example.com/top </loc><priority>1.0</priority></url><url><loc>http://example.com/plain</loc></url></urlset>`, []string{"/top 2.0", "/plain 1.0"}},
+		{"Index", "text/xml", `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>http://example.com/sitemap-2.xml</loc></sitemap></sitemapindex>`, []string{"/sitemap-2.xml 0.0"}},
+		{"OtherXML", "application/xml", `<feed><link href="http://example.com/entry"/></feed>`, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, discovered := discover(t, "http://example.com/sitemap.xml", test.ContentType, test.Body, nil)
+
+			got := []string{}
+			for _, r := range discovered {
+				got = append(got, fmt.Sprintf("%s %.1f", r.URL.Path, scorer(r)))
+			}
+			if strings.Join(got, ",") != strings.Join(test.Want, ",") {
+				t.Fatalf("Response.Do() = %v, want %v", got, test.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package hopper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBlobNotFound    = errors.New("Blob not found")
+	ErrBlobNotReadable = errors.New("Blob storage is write only")
+)
+
+// Storage keeps raw bodies of fetched pages and assets. Put returns
+// reference blob can be read back with.
+type Storage interface {
+	Put(key string, r io.Reader) (string, error)
+	Get(ref string) (io.ReadCloser, error)
+}
+
+// MemoryStorage keeps blobs in memory.
+type MemoryStorage struct {
+	blobs sync.Map
+}
+
+func (ms *MemoryStorage) Put(key string, r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	ms.blobs.Store(key, data)
+	return key, nil
+}
+
+func (ms *MemoryStorage) Get(ref string) (io.ReadCloser, error) {
+	data, exists := ms.blobs.Load(ref)
+	if !exists {
+		return nil, fmt.Errorf("Blob %s: %w", ref, ErrBlobNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(data.([]byte))), nil
+}
+
+// FileStorage keeps blobs in files under Dir, sharded into two levels of
+// directories by hash of their key.
+type FileStorage struct {
+	Dir string
+}
+
+func (fs *FileStorage) Put(key string, r io.Reader) (string, error) {
+	name := fs.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return "", err
+	}
+
+	// Blob becomes visible only once it is complete.
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return key, nil
+}
+
+func (fs *FileStorage) Get(ref string) (io.ReadCloser, error) {
+	file, err := os.Open(fs.path(ref))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("Blob %s: %w", ref, ErrBlobNotFound)
+	}
+	return file, err
+}
+
+// path returns file of key. Keys too long to be file names are hashed.
+func (fs *FileStorage) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+
+	name := url.PathEscape(key)
+	if len(name) > 200 {
+		name = hash
+	}
+
+	return filepath.Join(fs.Dir, hash[:2], hash[2:4], name)
+}
+
+// ContentStorage stores blobs in Storage under sha256 of their content,
+// so that equal content is written once. References are hex encoded
+// hashes.
+type ContentStorage struct {
+	Storage Storage
+}
+
+func (cs *ContentStorage) Put(key string, r io.Reader) (string, error) {
+	// Content is spooled to disk, as hash is known only after reading it.
+	tmp, err := os.CreateTemp("", "hopper-blob-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		return "", err
+	}
+	ref := hex.EncodeToString(h.Sum(nil))
+
+	existing, err := cs.Storage.Get(ref)
+	if err == nil {
+		existing.Close()
+		return ref, nil
+	}
+	// Write only storages deduplicate keys on their own.
+	if !errors.Is(err, ErrBlobNotFound) && !errors.Is(err, ErrBlobNotReadable) {
+		return "", err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := cs.Storage.Put(ref, tmp); err != nil {
+		return "", err
+	}
+
+	return ref, nil
+}
+
+func (cs *ContentStorage) Get(ref string) (io.ReadCloser, error) {
+	return cs.Storage.Get(ref)
+}
+
+// BundleStorage writes blobs as entries of tar or zip archive. Each key
+// is written once. Archive is complete after Close.
+type BundleStorage struct {
+	sync.Mutex
+
+	tar     *tar.Writer
+	zip     *zip.Writer
+	written map[string]bool
+}
+
+func NewTarStorage(w io.Writer) *BundleStorage {
+	return &BundleStorage{tar: tar.NewWriter(w), written: map[string]bool{}}
+}
+
+func NewZipStorage(w io.Writer) *BundleStorage {
+	return &BundleStorage{zip: zip.NewWriter(w), written: map[string]bool{}}
+}
+
+func (bs *BundleStorage) Put(key string, r io.Reader) (string, error) {
+	bs.Lock()
+	defer bs.Unlock()
+
+	name := url.PathEscape(key)
+	if bs.written[name] {
+		return key, nil
+	}
+
+	if bs.zip != nil {
+		w, err := bs.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			return "", err
+		}
+	} else {
+		// Tar header needs size before content.
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Now()}
+		if err := bs.tar.WriteHeader(header); err != nil {
+			return "", err
+		}
+		if _, err := bs.tar.Write(data); err != nil {
+			return "", err
+		}
+	}
+
+	bs.written[name] = true
+	return key, nil
+}
+
+func (bs *BundleStorage) Get(ref string) (io.ReadCloser, error) {
+	return nil, ErrBlobNotReadable
+}
+
+// Close finishes archive, it does not close underlying writer.
+func (bs *BundleStorage) Close() error {
+	bs.Lock()
+	defer bs.Unlock()
+
+	if bs.zip != nil {
+		return bs.zip.Close()
+	}
+	return bs.tar.Close()
+}
+
+type ArchiveMiddleware struct {
+	Storage Storage
+}
+
+// Archive writes body of each response to storage under its url. Reference
+// of stored body is set on response. Streamed downloads are stored by
+// DownloadsMiddleware, so it must be registered first.
+func Archive(crawler *Crawler, storage Storage) *ArchiveMiddleware {
+	am := &ArchiveMiddleware{Storage: storage}
+
+	crawler.OnResponse(func(r *Response) error {
+		if r.NotModified || r.Download != nil {
+			return nil
+		}
+
+		body, err := r.Bytes()
+		if err != nil {
+			return fmt.Errorf("Archive: %w", err)
+		}
+
+		ref, err := am.Storage.Put(r.URL.String(), bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("Archive: %w", err)
+		}
+		r.Blob = ref
+
+		return nil
+	})
+
+	return am
+}
@@ -0,0 +1,90 @@
+package hopper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	key := "https://example.com/a/page?q=1"
+	content := "<html>page</html>"
+
+	tests := []struct {
+		Name    string
+		Storage Storage
+	}{
+		{"Memory", &MemoryStorage{}},
+		{"File", &FileStorage{Dir: t.TempDir()}},
+		{"Content", &ContentStorage{Storage: &FileStorage{Dir: t.TempDir()}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			ref, err := test.Storage.Put(key, strings.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := test.Storage.Get(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(r)
+			r.Close()
+			if string(got) != content {
+				t.Fatalf("Storage.Get = %s, want %s", got, content)
+			}
+
+			if _, err := test.Storage.Get("missing"); !errors.Is(err, ErrBlobNotFound) {
+				t.Fatalf("Storage.Get = %v, want %v", err, ErrBlobNotFound)
+			}
+		})
+	}
+
+	t.Run("ContentDeduplicates", func(t *testing.T) {
+		memory := &MemoryStorage{}
+		cs := &ContentStorage{Storage: memory}
+
+		first, _ := cs.Put("https://example.com/a", strings.NewReader(content))
+		second, _ := cs.Put("https://example.com/b", strings.NewReader(content))
+		other, _ := cs.Put("https://example.com/c", strings.NewReader("other"))
+
+		if first != second || first == other || len(first) != 64 {
+			t.Fatalf("ContentStorage.Put = %s, %s, %s, want equal refs for equal content", first, second, other)
+		}
+	})
+
+	t.Run("Bundles", func(t *testing.T) {
+		var tarBuf, zipBuf bytes.Buffer
+		for _, bs := range []*BundleStorage{NewTarStorage(&tarBuf), NewZipStorage(&zipBuf)} {
+			bs.Put(key, strings.NewReader(content))
+			bs.Put(key, strings.NewReader(content))
+			bs.Put("other", strings.NewReader("other"))
+			if err := bs.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		names := []string{}
+		tr := tar.NewReader(&tarBuf)
+		for header, err := tr.Next(); err == nil; header, err = tr.Next() {
+			names = append(names, header.Name)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(zipBuf.Bytes()), int64(zipBuf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range zr.File {
+			names = append(names, file.Name)
+		}
+
+		if len(names) != 4 || names[1] != "other" || names[0] != names[2] {
+			t.Fatalf("entries = %v, want each key once in both bundles", names)
+		}
+	})
+}
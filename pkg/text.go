@@ -0,0 +1,68 @@
+package hopper
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// ExtractText returns visible text of html document with whitespace collapsed.
+func ExtractText(body []byte) string {
+	var b strings.Builder
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	skip := 0
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if isInvisible(string(name)) {
+				skip++
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if isInvisible(string(name)) && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(tokenizer.Text())
+				b.WriteByte(' ')
+			}
+		}
+	}
+}
+
+// Tokenize splits text into lowercased words.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// nodeText returns text content of html node with whitespace collapsed.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var f func(*html.Node)
+
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			f(ch)
+		}
+	}
+	f(n)
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func isInvisible(tag string) bool {
+	return tag == "script" || tag == "style" || tag == "noscript" || tag == "template"
+}
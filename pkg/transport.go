@@ -0,0 +1,297 @@
+package hopper
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultDNSTimeout       = 5 * time.Second
+	DefaultConnectTimeout   = 10 * time.Second
+	DefaultTLSTimeout       = 10 * time.Second
+	DefaultFirstByteTimeout = 10 * time.Second
+	DefaultIdleConnTimeout  = 90 * time.Second
+	DefaultKeepAlive        = 30 * time.Second
+)
+
+// Timeouts limit phases of request. Zero timeout is default one, negative
+// timeout is unlimited. Total limits whole request, including redirects
+// and reading of body.
+type Timeouts struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+}
+
+func (t Timeouts) dns() time.Duration       { return timeout(t.DNS, DefaultDNSTimeout) }
+func (t Timeouts) connect() time.Duration   { return timeout(t.Connect, DefaultConnectTimeout) }
+func (t Timeouts) tls() time.Duration       { return timeout(t.TLS, DefaultTLSTimeout) }
+func (t Timeouts) firstByte() time.Duration { return timeout(t.FirstByte, DefaultFirstByteTimeout) }
+func (t Timeouts) total() time.Duration     { return timeout(t.Total, DefaultClientTimeout) }
+
+// timeout returns d or default, 0 if it is unlimited.
+func timeout(d time.Duration, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// NewTransport creates transport with phases limited by timeouts, which
+// speaks HTTP/2 with servers supporting it. Connections to single host
+// are limited by perHost, which is also number of idle connections kept
+// alive for it. Connections are unlimited if perHost is 0.
+func NewTransport(timeouts Timeouts, perHost int) *http.Transport {
+	dialer := &resolvingDialer{
+		Dialer: net.Dialer{Timeout: timeouts.connect(), KeepAlive: DefaultKeepAlive},
+		DNS:    timeouts.dns(),
+	}
+
+	idle := perHost
+	if idle == 0 {
+		idle = http.DefaultMaxIdleConnsPerHost
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   timeouts.tls(),
+		ResponseHeaderTimeout: timeouts.firstByte(),
+		ExpectContinueTimeout: time.Second,
+		MaxConnsPerHost:       perHost,
+		MaxIdleConnsPerHost:   idle,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       DefaultIdleConnTimeout,
+	}
+}
+
+// resolvingDialer resolves host within DNS timeout before connecting to
+// its addresses in turn, all of them within timeout of Dialer.
+type resolvingDialer struct {
+	Dialer net.Dialer
+	DNS    time.Duration
+}
+
+func (d *resolvingDialer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.Dialer.DialContext(ctx, network, addr)
+	}
+
+	lookup := ctx
+	if d.DNS > 0 {
+		var cancel context.CancelFunc
+		lookup, cancel = context.WithTimeout(ctx, d.DNS)
+		defer cancel()
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(lookup, host)
+	if err != nil {
+		return nil, fmt.Errorf("DNS: %w", err)
+	}
+
+	connect := ctx
+	if d.Dialer.Timeout > 0 {
+		var cancel context.CancelFunc
+		connect, cancel = context.WithTimeout(ctx, d.Dialer.Timeout)
+		defer cancel()
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = d.Dialer.DialContext(connect, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// idleBody cancels request whose body was not read from within timeout.
+type idleBody struct {
+	io.ReadCloser
+
+	timer   *time.Timer
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+func newIdleBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *idleBody {
+	return &idleBody{ReadCloser: body, timer: time.AfterFunc(timeout, cancel), timeout: timeout, cancel: cancel}
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// altSvcTransport sends requests to hosts which advertised HTTP/3 with
+// Alt-Svc header through HTTP3, others through Transport. Hosts which
+// fail over HTTP/3 are retried over Transport and not tried again until
+// they advertise it again.
+type altSvcTransport struct {
+	Transport http.RoundTripper
+	HTTP3     http.RoundTripper
+
+	hosts sync.Map
+}
+
+func (t *altSvcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, proxied := req.Context().Value(proxyContextKey{}).(*url.URL)
+	if _, ok := t.hosts.Load(req.URL.Host); ok && req.URL.Scheme == "https" && !proxied {
+		res, err := t.HTTP3.RoundTrip(req)
+		if err == nil {
+			return res, nil
+		}
+
+		t.hosts.Delete(req.URL.Host)
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, err
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+
+	res, err := t.Transport.RoundTrip(req)
+	if err == nil && req.URL.Scheme == "https" {
+		t.remember(req.URL.Host, req.URL.Port(), res.Header.Get("Alt-Svc"))
+	}
+	return res, err
+}
+
+// remember records whether host advertises HTTP/3 on its own port.
+func (t *altSvcTransport) remember(host string, port string, altSvc string) {
+	if altSvc == "" {
+		return
+	}
+	if strings.TrimSpace(altSvc) == "clear" {
+		t.hosts.Delete(host)
+		return
+	}
+	if port == "" {
+		port = "443"
+	}
+
+	for _, service := range strings.Split(altSvc, ",") {
+		alternative, _, _ := strings.Cut(service, ";")
+		protocol, authority, _ := strings.Cut(strings.TrimSpace(alternative), "=")
+		if protocol == "h3" && strings.Trim(authority, `"`) == ":"+port {
+			t.hosts.Store(host, true)
+			return
+		}
+	}
+}
+
+// Timing is breakdown of time request spent in its phases. Phases which
+// were skipped, like connecting for reused connection, are zero.
+// FirstByte is measured from request being written and Total from start
+// of request until its body was read.
+type Timing struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+	Reused    bool
+
+	start time.Time
+}
+
+type timingContextKey struct{}
+
+// timingTrace records timing of request and its redirects, last of them
+// counts.
+type timingTrace struct {
+	sync.Mutex
+
+	timing  Timing
+	dns     time.Time
+	connect time.Time
+	tls     time.Time
+	wrote   time.Time
+}
+
+// withTiming returns context which records timing of request.
+func withTiming(ctx context.Context) context.Context {
+	t := &timingTrace{timing: Timing{start: time.Now()}}
+
+	since := func(start *time.Time, phase *time.Duration) {
+		t.Lock()
+		defer t.Unlock()
+		if !start.IsZero() {
+			*phase = time.Since(*start)
+		}
+	}
+	mark := func(start *time.Time) {
+		t.Lock()
+		defer t.Unlock()
+		*start = time.Now()
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { mark(&t.dns) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { since(&t.dns, &t.timing.DNS) },
+		ConnectStart:      func(string, string) { mark(&t.connect) },
+		ConnectDone:       func(string, string, error) { since(&t.connect, &t.timing.Connect) },
+		TLSHandshakeStart: func() { mark(&t.tls) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { since(&t.tls, &t.timing.TLS) },
+		WroteRequest:      func(httptrace.WroteRequestInfo) { mark(&t.wrote) },
+		GotFirstResponseByte: func() {
+			since(&t.wrote, &t.timing.FirstByte)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.Lock()
+			defer t.Unlock()
+			if info.Reused {
+				t.timing = Timing{Reused: true, start: t.timing.start}
+			}
+		},
+	}
+
+	return httptrace.WithClientTrace(context.WithValue(ctx, timingContextKey{}, t), trace)
+}
+
+// requestTiming returns timing recorded for request so far.
+func requestTiming(req *http.Request) Timing {
+	if req == nil {
+		return Timing{}
+	}
+	t, ok := req.Context().Value(timingContextKey{}).(*timingTrace)
+	if !ok {
+		return Timing{}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	timing := t.timing
+	timing.Total = time.Since(timing.start)
+	return timing
+}
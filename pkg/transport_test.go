@@ -0,0 +1,120 @@
+package hopper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		_, port, _ := strings.Cut(r.Host, ":")
+		w.Header().Set("Alt-Svc", `h3=":`+port+`"; ma=60`)
+		io.WriteString(w, "<html></html>")
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	seed := &Request{}
+	seed.Init()
+	seed.Properties["AllowedDepth"] = 10
+
+	client := func(timeouts Timeouts, http3 http.RoundTripper) *Client {
+		transport := NewTransport(timeouts, 2)
+		transport.TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+		c := &Client{Client: &http.Client{Transport: transport}, HTTP3: http3}
+		c.Init()
+		return c
+	}
+	fetch := func(c *Client, path string) (*Response, error) {
+		req, _ := seed.New(http.MethodGet, srv.URL+path)
+		res, err := c.Fetch(context.Background(), req)
+		if err != nil {
+			return nil, err
+		}
+		_, err = res.Bytes()
+		return res, err
+	}
+
+	t.Run("OwnClient", func(t *testing.T) {
+		c := &Client{Timeouts: Timeouts{Total: time.Minute}}
+		c.Init()
+
+		if http.DefaultClient.Timeout != 0 || c.Client.Timeout != time.Minute {
+			t.Fatalf("Client.Timeout = %v, http.DefaultClient.Timeout = %v, want %v and 0", c.Client.Timeout, http.DefaultClient.Timeout, time.Minute)
+		}
+	})
+
+	t.Run("Timing", func(t *testing.T) {
+		c := client(Timeouts{}, nil)
+
+		first, err := fetch(c, "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.Protocol != "HTTP/2.0" || first.Timing.Reused || first.Timing.Connect <= 0 || first.Timing.TLS <= 0 || first.Timing.FirstByte <= 0 || first.Timing.Total < first.Timing.FirstByte {
+			t.Fatalf("Response = %s %+v, want timing of new HTTP/2 connection", first.Protocol, first.Timing)
+		}
+
+		second, err := fetch(c, "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !second.Timing.Reused || second.Timing.TLS != 0 || second.Timing.FirstByte <= 0 {
+			t.Fatalf("Response.Timing = %+v, want timing of reused connection", second.Timing)
+		}
+	})
+
+	t.Run("FirstByteTimeout", func(t *testing.T) {
+		c := client(Timeouts{FirstByte: 50 * time.Millisecond}, nil)
+
+		if _, err := fetch(c, "/slow"); err == nil || !strings.Contains(err.Error(), "timeout awaiting response headers") {
+			t.Fatalf("err = %v, want first byte timeout", err)
+		}
+	})
+
+	t.Run("HTTP3", func(t *testing.T) {
+		var calls atomic.Int32
+		var fail atomic.Bool
+		http3 := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			if fail.Load() {
+				return nil, errors.New("QUIC handshake failed")
+			}
+			return &http.Response{StatusCode: http.StatusOK, Proto: "HTTP/3.0", Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+		})
+		c := client(Timeouts{}, http3)
+
+		protocols := []string{}
+		for i := 0; i < 4; i++ {
+			// Third request fails over HTTP/3 and falls back to HTTP/2.
+			fail.Store(i == 2)
+			res, err := fetch(c, "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			protocols = append(protocols, res.Protocol)
+		}
+
+		want := "HTTP/2.0 HTTP/3.0 HTTP/2.0 HTTP/3.0"
+		if strings.Join(protocols, " ") != want || calls.Load() != 3 {
+			t.Fatalf("protocols = %v with %d HTTP/3 requests, want %s with 3", protocols, calls.Load(), want)
+		}
+	})
+}
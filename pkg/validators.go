@@ -0,0 +1,73 @@
+package hopper
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Validators are HTTP validators of previously fetched url.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// ValidatorStore remembers validators of fetched urls, so that client
+// can make conditional requests for them.
+type ValidatorStore interface {
+	Get(uri string) (Validators, bool)
+	Set(uri string, validators Validators)
+}
+
+type MemoryValidatorStore struct {
+	validators sync.Map
+}
+
+func (ms *MemoryValidatorStore) Get(uri string) (Validators, bool) {
+	validators, exists := ms.validators.Load(uri)
+	if !exists {
+		return Validators{}, false
+	}
+	return validators.(Validators), true
+}
+
+func (ms *MemoryValidatorStore) Set(uri string, validators Validators) {
+	ms.validators.Store(uri, validators)
+}
+
+// setConditional adds conditional headers to req if validators of its
+// url are known.
+func setConditional(store ValidatorStore, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return
+	}
+	// Range requests continue known representation with If-Range.
+	if req.Header.Get("Range") != "" {
+		return
+	}
+
+	validators, exists := store.Get(req.URL.String())
+	if !exists {
+		return
+	}
+	if validators.ETag != "" && req.Header.Get("If-None-Match") == "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" && req.Header.Get("If-Modified-Since") == "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+}
+
+// storeValidators remembers validators of successful response under uri
+// it was requested with, which redirects may have changed. Conditional
+// headers of next request are sent to its final url again.
+func storeValidators(store ValidatorStore, uri *url.URL, res *http.Response) {
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return
+	}
+
+	validators := Validators{ETag: res.Header.Get("ETag"), LastModified: res.Header.Get("Last-Modified")}
+	if validators.ETag != "" || validators.LastModified != "" {
+		store.Set(uri.String(), validators)
+	}
+}
@@ -0,0 +1,47 @@
+package hopper
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "<html></html>")
+	}))
+	defer srv.Close()
+
+	crawler := Crawler{Client: srv.Client()}
+	crawler.Init()
+
+	root := crawler.Root()
+	req, _ := root.New(http.MethodGet, srv.URL+"/old")
+
+	statuses := []int{}
+	for i := 0; i < 2; i++ {
+		res, err := crawler.client.DoRequest(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drainBody(res.Body)
+		statuses = append(statuses, res.StatusCode)
+	}
+
+	if statuses[0] != http.StatusOK || statuses[1] != http.StatusNotModified {
+		t.Fatalf("statuses = %v, want 200 then 304 of redirected url", statuses)
+	}
+	if _, exists := crawler.Validators.Get(srv.URL + "/old"); !exists {
+		t.Fatalf("Validators.Get(/old) = false, want validators of requested url")
+	}
+}